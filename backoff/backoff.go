@@ -0,0 +1,36 @@
+// Package backoff keeps long-lived connections, like a change feed or a
+// Kafka consumer, open by reconnecting with exponential backoff.
+package backoff
+
+import (
+	"context"
+	"time"
+)
+
+// Backoff is the delay between reconnects. It starts at Min and doubles
+// after every attempt that fails before connecting, up to Max.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Reconnect calls connect until ctx is cancelled. connect holds one
+// connection open until it fails, and calls connected once the connection is
+// up so the next failure waits Min again. failed is told about every error
+// and the delay before the next attempt.
+func (b Backoff) Reconnect(ctx context.Context, connect func(connected func()) error, failed func(err error, delay time.Duration)) {
+	delay := b.Min
+	for {
+		err := connect(func() { delay = b.Min })
+		if ctx.Err() != nil {
+			return
+		}
+		failed(err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, b.Max)
+	}
+}
@@ -0,0 +1,175 @@
+// Package colors compares filament colors the way people see them: hex
+// colors are converted to CIE Lab and compared with the CIEDE2000 color
+// difference (ΔE).
+package colors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB is an sRGB color.
+type RGB struct {
+	R, G, B uint8
+}
+
+// Lab is a color in CIE L*a*b* (D65 white point).
+type Lab struct {
+	L, A, B float64
+}
+
+// ParseHex parses "RRGGBB", "RGB" or either with a leading "#". An alpha
+// channel ("RRGGBBAA") is ignored.
+func ParseHex(hex string) (RGB, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	switch len(s) {
+	case 3:
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	case 8:
+		s = s[:6]
+	}
+	if len(s) != 6 {
+		return RGB{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+	return RGB{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+// ParseHexList parses a comma-separated list of hex colors, such as a
+// filament's multi_color_hexes. Invalid entries are skipped.
+func ParseHexList(hexes string) []RGB {
+	var out []RGB
+	for _, hex := range strings.Split(hexes, ",") {
+		if c, err := ParseHex(hex); err == nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Hex formats c as "RRGGBB".
+func (c RGB) Hex() string {
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// D65 reference white.
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// Lab converts c to CIE Lab.
+func (c RGB) Lab() Lab {
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX
+	y := (0.2126729*r + 0.7151522*g + 0.0721750*b) / whiteY
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// linear undoes the sRGB gamma of one channel.
+func linear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29
+}
+
+// DeltaE2000 is the CIEDE2000 difference between two colors. Below about 1
+// the difference is not noticeable, up to about 10 the colors look alike at
+// a glance.
+func DeltaE2000(c1, c2 Lab) float64 {
+	const pow25to7 = 6103515625 // 25^7
+
+	cBar := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25to7)))
+	a1, a2 := (1+g)*c1.A, (1+g)*c2.A
+	cp1, cp2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+	hp1, hp2 := hueAngle(c1.B, a1), hueAngle(c2.B, a2)
+
+	dL := c2.L - c1.L
+	dC := cp2 - cp1
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		switch {
+		case dh > 180:
+			dh -= 360
+		case dh < -180:
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(radians(dh/2))
+
+	lBar := (c1.L + c2.L) / 2
+	cpBar := (cp1 + cp2) / 2
+	hBar := hp1 + hp2
+	if cp1*cp2 != 0 {
+		switch {
+		case math.Abs(hp1-hp2) <= 180:
+			hBar /= 2
+		case hBar < 360:
+			hBar = (hBar + 360) / 2
+		default:
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBar-30)) +
+		0.24*math.Cos(radians(2*hBar)) +
+		0.32*math.Cos(radians(3*hBar+6)) -
+		0.20*math.Cos(radians(4*hBar-63))
+	dTheta := 30 * math.Exp(-math.Pow((hBar-275)/25, 2))
+	cpBar7 := math.Pow(cpBar, 7)
+	rc := 2 * math.Sqrt(cpBar7/(cpBar7+pow25to7))
+	sl := 1 + 0.015*(lBar-50)*(lBar-50)/math.Sqrt(20+(lBar-50)*(lBar-50))
+	sc := 1 + 0.045*cpBar
+	sh := 1 + 0.015*cpBar*t
+	rt := -math.Sin(radians(2*dTheta)) * rc
+
+	l, c, h := dL/sl, dC/sc, dH/sh
+	return math.Sqrt(l*l + c*c + h*h + rt*c*h)
+}
+
+// hueAngle is the hue in degrees in [0, 360).
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Closest returns the smallest difference between target and any of
+// candidates, and false if there are none.
+func Closest(target Lab, candidates []RGB) (float64, bool) {
+	best, ok := math.Inf(1), false
+	for _, c := range candidates {
+		best, ok = min(best, DeltaE2000(target, c.Lab())), true
+	}
+	return best, ok
+}
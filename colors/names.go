@@ -0,0 +1,130 @@
+package colors
+
+import (
+	"strings"
+)
+
+// Directions of multi-color filaments, as Spoolman's multi_color_direction.
+const (
+	// Coaxial filaments are co-extruded: the colors run side by side.
+	Coaxial = "coaxial"
+	// Longitudinal filaments change color along their length (gradients).
+	Longitudinal = "longitudinal"
+)
+
+// named is a palette entry with its Lab value precomputed.
+type named struct {
+	name string
+	lab  Lab
+}
+
+// palette holds the color names filaments are described with.
+var palette = func() []named {
+	entries := []struct {
+		name string
+		hex  string
+	}{
+		{"Black", "000000"},
+		{"Charcoal", "36454F"},
+		{"Dark Grey", "555555"},
+		{"Grey", "808080"},
+		{"Silver", "C0C0C0"},
+		{"Light Grey", "D3D3D3"},
+		{"White", "FFFFFF"},
+		{"Ivory", "FFFFF0"},
+		{"Cream", "FFFDD0"},
+		{"Beige", "F5F5DC"},
+		{"Khaki", "C3B091"},
+		{"Tan", "D2B48C"},
+		{"Brown", "8B4513"},
+		{"Dark Brown", "5C4033"},
+		{"Chocolate", "7B3F00"},
+		{"Bronze", "CD7F32"},
+		{"Copper", "B87333"},
+		{"Gold", "FFD700"},
+		{"Yellow", "FFFF00"},
+		{"Mustard", "FFDB58"},
+		{"Amber", "FFBF00"},
+		{"Orange", "FF7F00"},
+		{"Dark Orange", "E05A00"},
+		{"Coral", "FF7F50"},
+		{"Salmon", "FA8072"},
+		{"Peach", "FFE5B4"},
+		{"Red", "FF0000"},
+		{"Crimson", "DC143C"},
+		{"Dark Red", "8B0000"},
+		{"Maroon", "800000"},
+		{"Burgundy", "800020"},
+		{"Pink", "FFC0CB"},
+		{"Hot Pink", "FF69B4"},
+		{"Magenta", "FF00FF"},
+		{"Purple", "800080"},
+		{"Violet", "8F00FF"},
+		{"Lavender", "E6E6FA"},
+		{"Lilac", "C8A2C8"},
+		{"Indigo", "4B0082"},
+		{"Navy", "000080"},
+		{"Dark Blue", "00008B"},
+		{"Blue", "0000FF"},
+		{"Royal Blue", "4169E1"},
+		{"Sky Blue", "87CEEB"},
+		{"Light Blue", "ADD8E6"},
+		{"Cyan", "00FFFF"},
+		{"Turquoise", "40E0D0"},
+		{"Teal", "008080"},
+		{"Mint", "98FF98"},
+		{"Light Green", "90EE90"},
+		{"Lime", "00FF00"},
+		{"Green", "008000"},
+		{"Forest Green", "228B22"},
+		{"Dark Green", "006400"},
+		{"Olive", "808000"},
+	}
+	p := make([]named, len(entries))
+	for i, e := range entries {
+		c, err := ParseHex(e.hex)
+		if err != nil {
+			panic(err)
+		}
+		p[i] = named{name: e.name, lab: c.Lab()}
+	}
+	return p
+}()
+
+// Name returns the name of the palette color closest to c.
+func Name(c RGB) string {
+	lab := c.Lab()
+	best, bestDist := "", 0.0
+	for _, p := range palette {
+		if d := DeltaE2000(lab, p.lab); best == "" || d < bestDist {
+			best, bestDist = p.name, d
+		}
+	}
+	return best
+}
+
+// Describe names a filament with the given colors, e.g. "Teal", "Red → Gold
+// gradient" for a longitudinal multi-color filament or "Black & Gold
+// co-extruded" for a coaxial one. Neighbouring colors with the same name
+// are named once. It returns "" without colors.
+func Describe(cs []RGB, direction string) string {
+	var names []string
+	for _, c := range cs {
+		name := Name(c)
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) == 1:
+		return names[0]
+	case direction == Longitudinal:
+		return strings.Join(names, " → ") + " gradient"
+	case direction == Coaxial:
+		return strings.Join(names, " & ") + " co-extruded"
+	default:
+		return strings.Join(names, ", ")
+	}
+}
@@ -0,0 +1,805 @@
+// Package config loads the server configuration.
+//
+// Values are resolved in this order, later sources winning:
+// built-in defaults, the YAML config file, environment variables and
+// finally command line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tryy3/filament-chamber/events"
+	"github.com/tryy3/filament-chamber/layout"
+	"github.com/tryy3/filament-chamber/manager"
+	"github.com/tryy3/filament-chamber/outbox"
+	"github.com/tryy3/filament-chamber/scale"
+	"github.com/tryy3/filament-chamber/spoolman"
+	"github.com/tryy3/filament-chamber/transfers"
+)
+
+// DefaultFile is loaded when no config file is given and it exists.
+const DefaultFile = "config.yaml"
+
+const redacted = "REDACTED"
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Spoolman SpoolmanConfig `yaml:"spoolman"`
+	Transfer TransferConfig `yaml:"transfer"`
+	LEDs     LEDConfig      `yaml:"leds"`
+	Scale    ScaleConfig    `yaml:"scale"`
+	// Chambers describes the physical layout, see package layout.
+	Chambers []layout.ChamberConfig `yaml:"chambers"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port"`
+}
+
+type SpoolmanConfig struct {
+	URL     string            `yaml:"url"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Headers map[string]string `yaml:"headers"`
+	TLS     TLSConfig         `yaml:"tls"`
+	// Subscribe keeps a websocket open to Spoolman's change feed, so changes
+	// made elsewhere show up live.
+	Subscribe bool `yaml:"subscribe"`
+	// CacheTTL is how long the spool list is reused before asking Spoolman
+	// again. Changes made through this app or seen on the change feed
+	// refresh it sooner.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type TransferConfig struct {
+	// Mode selects how location transfers are applied: "kafka" posts them
+	// to the Kafka HTTP bridge, "spoolman" updates the spool directly.
+	Mode string `yaml:"mode"`
+	// KafkaURL is the Kafka REST Proxy topic URL transfers are produced to,
+	// e.g. https://kafka.example.com/topics/<topic>.
+	KafkaURL string        `yaml:"kafka_url"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// Outbox stores transfers until they are delivered.
+	Outbox OutboxConfig `yaml:"outbox"`
+	// Results configures how the outcome of a transfer is learned.
+	Results TransferResultsConfig `yaml:"results"`
+}
+
+type TransferResultsConfig struct {
+	// URL is the REST Proxy topic URL transfer outcomes are consumed from.
+	// Without it, kafka mode transfers complete once Spoolman shows the spool
+	// at the new location.
+	URL string `yaml:"url"`
+	// Group is the consumer group. Every server instance needs its own.
+	Group string `yaml:"group"`
+	// Timeout marks a transfer unconfirmed when no outcome arrives this long
+	// after it was delivered.
+	Timeout time.Duration `yaml:"timeout"`
+	// PollInterval is how often Spoolman is checked when URL is not set.
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+type OutboxConfig struct {
+	// Dir holds one file per stored transfer.
+	Dir string `yaml:"dir"`
+	// MaxAttempts marks a transfer failed after this many delivery attempts.
+	MaxAttempts int `yaml:"max_attempts"`
+	// MinBackoff is the delay after the first failure, doubled per failure
+	// up to MaxBackoff.
+	MinBackoff time.Duration `yaml:"min_backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Retention is how long delivered transfers stay visible.
+	Retention time.Duration `yaml:"retention"`
+}
+
+type LEDConfig struct {
+	Controllers []ControllerConfig `yaml:"controllers"`
+	// FrameRate is the number of animation frames streamed per second.
+	FrameRate int `yaml:"frame_rate"`
+	// LocateDuration is how long a slot stays lit after "Locate".
+	LocateDuration time.Duration `yaml:"locate_duration"`
+	// LocateBlink blinks the located slot instead of lighting it steadily.
+	LocateBlink bool `yaml:"locate_blink"`
+	// Filter mirrors spool filter results on the chamber LEDs.
+	Filter FilterLEDConfig `yaml:"filter"`
+	// Simulate sends every frame to the built-in controller simulator instead
+	// of the configured controllers, see the admin page.
+	Simulate bool `yaml:"simulate"`
+}
+
+type FilterLEDConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// IdleTimeout turns the LEDs off when no filter request arrives in time.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// Dim is the brightness factor (0-1) for occupied slots that don't match.
+	Dim float64 `yaml:"dim"`
+}
+
+type ScaleConfig struct {
+	// Window is the number of readings the median is taken over.
+	Window int `yaml:"window"`
+	// Tolerance is how far in grams the weight may wander while settling.
+	Tolerance float64 `yaml:"tolerance"`
+	// Settle is how long the weight has to stay steady to be recorded.
+	Settle time.Duration `yaml:"settle"`
+	// MinWeight is the lightest load in grams counted as a spool.
+	MinWeight float64 `yaml:"min_weight"`
+	// ScanTimeout is how long a scanned spool waits to be put on the scale.
+	ScanTimeout time.Duration `yaml:"scan_timeout"`
+	// MQTT receives readings from a broker, see ScaleMQTTConfig.
+	MQTT ScaleMQTTConfig `yaml:"mqtt"`
+	// Simulate adds a fake scale, see POST /simulator/scale/{scale}/load.
+	Simulate bool `yaml:"simulate"`
+}
+
+type ScaleMQTTConfig struct {
+	// Broker is the broker URL; readings are only read from MQTT if set.
+	Broker string `yaml:"broker"`
+	// Topic readings are published to, wildcards allowed.
+	Topic    string `yaml:"topic"`
+	ClientID string `yaml:"client_id"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	QoS      byte   `yaml:"qos"`
+}
+
+type ControllerConfig struct {
+	// Name is referenced by the chamber LED strips.
+	Name string `yaml:"name"`
+	// Driver is the controller protocol: "http" (default), "wled" or "mqtt".
+	Driver string `yaml:"driver"`
+	// Address is the controller URL, or the broker URL for the mqtt driver.
+	Address string `yaml:"address"`
+	// Timeout bounds each request to the controller.
+	Timeout time.Duration `yaml:"timeout"`
+	// MQTT configures the mqtt driver.
+	MQTT MQTTConfig `yaml:"mqtt"`
+}
+
+type MQTTConfig struct {
+	// Topic frames are published to, "{pin}" is replaced by the strip pin.
+	Topic    string `yaml:"topic"`
+	ClientID string `yaml:"client_id"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	QoS      byte   `yaml:"qos"`
+	Retain   bool   `yaml:"retain"`
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port: 8080,
+		},
+		Spoolman: SpoolmanConfig{
+			URL:       "https://spoolman.tryy3.dev/api/v1",
+			Timeout:   spoolman.DefaultTimeout,
+			Subscribe: true,
+			CacheTTL:  spoolman.DefaultCacheTTL,
+		},
+		Transfer: TransferConfig{
+			Mode:     "kafka",
+			KafkaURL: "https://kafka.tryy3.dev/topics/3dprinter-filament-transfer-initiated",
+			Timeout:  10 * time.Second,
+			Outbox: OutboxConfig{
+				Dir:         "data/outbox",
+				MaxAttempts: outbox.DefaultMaxAttempts,
+				MinBackoff:  outbox.DefaultMinBackoff,
+				MaxBackoff:  outbox.DefaultMaxBackoff,
+				Retention:   outbox.DefaultRetention,
+			},
+			Results: TransferResultsConfig{
+				Group:        "filament-chamber",
+				Timeout:      transfers.DefaultTimeout,
+				PollInterval: transfers.DefaultWatchInterval,
+			},
+		},
+		LEDs: LEDConfig{
+			Controllers: []ControllerConfig{
+				{
+					Name:    "chamber1",
+					Address: "http://192.168.1.243",
+					Timeout: manager.DefaultRequestTimeout,
+				},
+			},
+			FrameRate:      manager.DefaultFrameRate,
+			LocateDuration: 30 * time.Second,
+			Filter: FilterLEDConfig{
+				IdleTimeout: 2 * time.Minute,
+				Dim:         0.1,
+			},
+		},
+		Scale: ScaleConfig{
+			Window:      scale.DefaultWindow,
+			Tolerance:   scale.DefaultTolerance,
+			Settle:      scale.DefaultSettle,
+			MinWeight:   scale.DefaultMinWeight,
+			ScanTimeout: scale.DefaultScanTimeout,
+			MQTT: ScaleMQTTConfig{
+				Topic: "filament-chamber/scale/+",
+			},
+		},
+		Chambers: []layout.ChamberConfig{
+			{
+				ID:      "chamber1",
+				Default: true,
+				Rows:    []string{"A", "B", "C", "D", "E", "F"},
+				Columns: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+				LEDs: []layout.StripConfig{
+					{Controller: "chamber1", Pin: 2, Slots: []string{"B10", "B9", "B8", "B7", "B6"}},
+					{Controller: "chamber1", Pin: 5, Slots: []string{"A10", "A9", "A8", "A7", "A6"}},
+				},
+			},
+		},
+	}
+}
+
+// flagValues holds the command line overrides. Only flags that were
+// explicitly set are applied.
+type flagValues struct {
+	configFile      string
+	port            int
+	spoolmanURL     string
+	spoolmanTimeout time.Duration
+	kafkaURL        string
+	transferMode    string
+	resultsURL      string
+	simulateLEDs    bool
+	simulateScale   bool
+	set             map[string]bool
+}
+
+// Load builds the configuration from defaults, the config file, the
+// environment and the given command line arguments (without the program
+// name), then validates it.
+func Load(args []string) (*Config, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	path := flags.configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	flags.apply(&cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func parseFlags(args []string) (*flagValues, error) {
+	fv := &flagValues{set: map[string]bool{}}
+	fs := flag.NewFlagSet("filament-chamber", flag.ContinueOnError)
+	fs.StringVar(&fv.configFile, "config", "", "path to the YAML config file (env CONFIG_FILE)")
+	fs.IntVar(&fv.port, "port", 0, "HTTP port to listen on (env PORT)")
+	fs.StringVar(&fv.spoolmanURL, "spoolman-url", "", "Spoolman API root, e.g. http://localhost:7912/api/v1 (env SPOOLMAN_URL)")
+	fs.DurationVar(&fv.spoolmanTimeout, "spoolman-timeout", 0, "timeout for Spoolman requests (env SPOOLMAN_TIMEOUT)")
+	fs.StringVar(&fv.kafkaURL, "kafka-url", "", "Kafka HTTP bridge topic URL for location transfers (env KAFKA_URL)")
+	fs.StringVar(&fv.resultsURL, "transfer-results-url", "", "REST Proxy topic URL transfer outcomes are read from (env TRANSFER_RESULTS_URL)")
+	fs.StringVar(&fv.transferMode, "transfer-mode", "", "how location transfers are applied: kafka or spoolman (env TRANSFER_MODE)")
+	fs.BoolVar(&fv.simulateLEDs, "simulate-leds", false, "send LED frames to the built-in simulator (env LED_SIMULATE)")
+	fs.BoolVar(&fv.simulateScale, "simulate-scale", false, "add a fake scale at /simulator/scale/{scale}/load (env SCALE_SIMULATE)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		fv.set[f.Name] = true
+	})
+	return fv, nil
+}
+
+func (fv *flagValues) apply(cfg *Config) {
+	if fv.set["port"] {
+		cfg.Server.Port = fv.port
+	}
+	if fv.set["spoolman-url"] {
+		cfg.Spoolman.URL = fv.spoolmanURL
+	}
+	if fv.set["spoolman-timeout"] {
+		cfg.Spoolman.Timeout = fv.spoolmanTimeout
+	}
+	if fv.set["kafka-url"] {
+		cfg.Transfer.KafkaURL = fv.kafkaURL
+	}
+	if fv.set["transfer-mode"] {
+		cfg.Transfer.Mode = fv.transferMode
+	}
+	if fv.set["transfer-results-url"] {
+		cfg.Transfer.Results.URL = fv.resultsURL
+	}
+	if fv.set["simulate-leds"] {
+		cfg.LEDs.Simulate = fv.simulateLEDs
+	}
+	if fv.set["simulate-scale"] {
+		cfg.Scale.Simulate = fv.simulateScale
+	}
+}
+
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv("PORT"); ok && v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("PORT: %q is not a number", v)
+		}
+		cfg.Server.Port = port
+	}
+	if v, ok := os.LookupEnv("SPOOLMAN_URL"); ok && v != "" {
+		cfg.Spoolman.URL = v
+	}
+	if v, ok := os.LookupEnv("SPOOLMAN_TIMEOUT"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SPOOLMAN_TIMEOUT: %q is not a duration (e.g. 10s)", v)
+		}
+		cfg.Spoolman.Timeout = d
+	}
+	if v, ok := os.LookupEnv("SPOOLMAN_SUBSCRIBE"); ok && v != "" {
+		subscribe, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("SPOOLMAN_SUBSCRIBE: %q is not a boolean", v)
+		}
+		cfg.Spoolman.Subscribe = subscribe
+	}
+	if v, ok := os.LookupEnv("SPOOLMAN_CACHE_TTL"); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("SPOOLMAN_CACHE_TTL: %q is not a duration (e.g. 30s)", v)
+		}
+		cfg.Spoolman.CacheTTL = d
+	}
+	if v, ok := os.LookupEnv("SPOOLMAN_AUTHORIZATION"); ok && v != "" {
+		if cfg.Spoolman.Headers == nil {
+			cfg.Spoolman.Headers = map[string]string{}
+		}
+		cfg.Spoolman.Headers["Authorization"] = v
+	}
+	if v, ok := os.LookupEnv("KAFKA_URL"); ok && v != "" {
+		cfg.Transfer.KafkaURL = v
+	}
+	if v, ok := os.LookupEnv("TRANSFER_MODE"); ok && v != "" {
+		cfg.Transfer.Mode = v
+	}
+	if v, ok := os.LookupEnv("TRANSFER_RESULTS_URL"); ok && v != "" {
+		cfg.Transfer.Results.URL = v
+	}
+	if v, ok := os.LookupEnv("LED_SIMULATE"); ok && v != "" {
+		simulate, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("LED_SIMULATE: %q is not a boolean", v)
+		}
+		cfg.LEDs.Simulate = simulate
+	}
+	if v, ok := os.LookupEnv("SCALE_MQTT_BROKER"); ok && v != "" {
+		cfg.Scale.MQTT.Broker = v
+	}
+	if v, ok := os.LookupEnv("SCALE_SIMULATE"); ok && v != "" {
+		simulate, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("SCALE_SIMULATE: %q is not a boolean", v)
+		}
+		cfg.Scale.Simulate = simulate
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports every problem found.
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+
+	if err := checkHTTPURL(c.Spoolman.URL); err != nil {
+		fail("spoolman.url", "%v", err)
+	}
+	if c.Spoolman.Timeout <= 0 {
+		fail("spoolman.timeout", "must be positive, got %s", c.Spoolman.Timeout)
+	}
+	if c.Spoolman.CacheTTL <= 0 {
+		fail("spoolman.cache_ttl", "must be positive, got %s", c.Spoolman.CacheTTL)
+	}
+	if (c.Spoolman.TLS.CertFile == "") != (c.Spoolman.TLS.KeyFile == "") {
+		fail("spoolman.tls", "cert_file and key_file must be set together")
+	}
+
+	switch c.Transfer.Mode {
+	case "kafka":
+		if err := checkHTTPURL(c.Transfer.KafkaURL); err != nil {
+			fail("transfer.kafka_url", "%v", err)
+		} else if _, _, err := events.ParseTopicURL(c.Transfer.KafkaURL); err != nil {
+			fail("transfer.kafka_url", "%v", err)
+		}
+	case "spoolman":
+	default:
+		fail("transfer.mode", "unknown mode %q, must be kafka or spoolman", c.Transfer.Mode)
+	}
+	if c.Transfer.Timeout <= 0 {
+		fail("transfer.timeout", "must be positive, got %s", c.Transfer.Timeout)
+	}
+	if c.Transfer.Outbox.Dir == "" {
+		fail("transfer.outbox.dir", "is required")
+	}
+	if c.Transfer.Outbox.MaxAttempts < 1 {
+		fail("transfer.outbox.max_attempts", "must be at least 1, got %d", c.Transfer.Outbox.MaxAttempts)
+	}
+	if c.Transfer.Outbox.MinBackoff <= 0 {
+		fail("transfer.outbox.min_backoff", "must be positive, got %s", c.Transfer.Outbox.MinBackoff)
+	}
+	if c.Transfer.Outbox.MaxBackoff < c.Transfer.Outbox.MinBackoff {
+		fail("transfer.outbox.max_backoff", "must not be less than min_backoff (%s), got %s", c.Transfer.Outbox.MinBackoff, c.Transfer.Outbox.MaxBackoff)
+	}
+	if c.Transfer.Outbox.Retention <= 0 {
+		fail("transfer.outbox.retention", "must be positive, got %s", c.Transfer.Outbox.Retention)
+	}
+	if c.Transfer.Results.URL != "" {
+		if err := checkHTTPURL(c.Transfer.Results.URL); err != nil {
+			fail("transfer.results.url", "%v", err)
+		} else if _, _, err := events.ParseTopicURL(c.Transfer.Results.URL); err != nil {
+			fail("transfer.results.url", "%v", err)
+		}
+		if c.Transfer.Results.Group == "" {
+			fail("transfer.results.group", "is required with transfer.results.url")
+		}
+	}
+	if c.Transfer.Results.Timeout <= 0 {
+		fail("transfer.results.timeout", "must be positive, got %s", c.Transfer.Results.Timeout)
+	}
+	if c.Transfer.Results.PollInterval <= 0 {
+		fail("transfer.results.poll_interval", "must be positive, got %s", c.Transfer.Results.PollInterval)
+	}
+
+	if c.LEDs.FrameRate < 1 || c.LEDs.FrameRate > 60 {
+		fail("leds.frame_rate", "must be between 1 and 60, got %d", c.LEDs.FrameRate)
+	}
+	if c.LEDs.LocateDuration <= 0 {
+		fail("leds.locate_duration", "must be positive, got %s", c.LEDs.LocateDuration)
+	}
+
+	if c.LEDs.Filter.IdleTimeout <= 0 {
+		fail("leds.filter.idle_timeout", "must be positive, got %s", c.LEDs.Filter.IdleTimeout)
+	}
+	if c.LEDs.Filter.Dim < 0 || c.LEDs.Filter.Dim > 1 {
+		fail("leds.filter.dim", "must be between 0 and 1, got %g", c.LEDs.Filter.Dim)
+	}
+
+	if c.Scale.Window < 1 || c.Scale.Window > 50 {
+		fail("scale.window", "must be between 1 and 50, got %d", c.Scale.Window)
+	}
+	if c.Scale.Tolerance <= 0 {
+		fail("scale.tolerance", "must be positive, got %g", c.Scale.Tolerance)
+	}
+	if c.Scale.Settle <= 0 {
+		fail("scale.settle", "must be positive, got %s", c.Scale.Settle)
+	}
+	if c.Scale.MinWeight <= 0 {
+		fail("scale.min_weight", "must be positive, got %g", c.Scale.MinWeight)
+	}
+	if c.Scale.ScanTimeout <= 0 {
+		fail("scale.scan_timeout", "must be positive, got %s", c.Scale.ScanTimeout)
+	}
+	if c.Scale.MQTT.Broker != "" {
+		if err := checkBrokerURL(c.Scale.MQTT.Broker); err != nil {
+			fail("scale.mqtt.broker", "%v", err)
+		}
+		if c.Scale.MQTT.Topic == "" {
+			fail("scale.mqtt.topic", "is required with scale.mqtt.broker")
+		}
+		if c.Scale.MQTT.QoS > 2 {
+			fail("scale.mqtt.qos", "must be 0, 1 or 2, got %d", c.Scale.MQTT.QoS)
+		}
+	}
+
+	controllers := map[string]bool{}
+	for i, ctrl := range c.LEDs.Controllers {
+		field := fmt.Sprintf("leds.controllers[%d]", i)
+		if ctrl.Name == "" {
+			fail(field+".name", "is required")
+		} else if controllers[ctrl.Name] {
+			fail(field+".name", "controller %q is configured more than once", ctrl.Name)
+		}
+		controllers[ctrl.Name] = true
+		switch ctrl.Driver {
+		case "", manager.DriverHTTP, manager.DriverWLED:
+			if err := checkHTTPURL(ctrl.Address); err != nil {
+				fail(field+".address", "%v", err)
+			}
+		case manager.DriverMQTT:
+			if err := checkBrokerURL(ctrl.Address); err != nil {
+				fail(field+".address", "%v", err)
+			}
+			if ctrl.MQTT.Topic == "" {
+				fail(field+".mqtt.topic", "is required")
+			}
+			if ctrl.MQTT.QoS > 2 {
+				fail(field+".mqtt.qos", "must be 0, 1 or 2, got %d", ctrl.MQTT.QoS)
+			}
+		default:
+			fail(field+".driver", "unknown driver %q, must be http, wled or mqtt", ctrl.Driver)
+		}
+		if ctrl.Timeout < 0 {
+			fail(field+".timeout", "must not be negative, got %s", ctrl.Timeout)
+		}
+	}
+
+	if l, err := layout.New(c.Chambers); err != nil {
+		errs = append(errs, err)
+	} else {
+		pins := map[string]bool{}
+		for _, chamber := range l.Chambers() {
+			for i, strip := range chamber.Strips {
+				field := fmt.Sprintf("chambers[%s].leds[%d]", chamber.ID, i)
+				if !controllers[strip.Controller] {
+					fail(field+".controller", "unknown LED controller %q", strip.Controller)
+				}
+				if strip.Pin < 0 {
+					fail(field+".pin", "must not be negative, got %d", strip.Pin)
+				}
+				key := fmt.Sprintf("%s/%d", strip.Controller, strip.Pin)
+				if pins[key] {
+					fail(field+".pin", "pin %d of controller %q is used by more than one strip", strip.Pin, strip.Controller)
+				}
+				pins[key] = true
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func checkHTTPURL(raw string) error {
+	if raw == "" {
+		return errors.New("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must start with http:// or https://", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+func checkBrokerURL(raw string) error {
+	if raw == "" {
+		return errors.New("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL: %v", raw, err)
+	}
+	switch u.Scheme {
+	case "tcp", "ssl", "tls", "mqtt", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("%q must start with tcp://, ssl://, mqtt://, mqtts://, ws:// or wss://", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to log:
+// header values and passwords are replaced.
+func (c Config) Redacted() Config {
+	out := c
+	out.Spoolman.URL = redactURL(c.Spoolman.URL)
+	out.Transfer.KafkaURL = redactURL(c.Transfer.KafkaURL)
+	out.Transfer.Results.URL = redactURL(c.Transfer.Results.URL)
+	if c.Spoolman.Headers != nil {
+		out.Spoolman.Headers = make(map[string]string, len(c.Spoolman.Headers))
+		for k := range c.Spoolman.Headers {
+			out.Spoolman.Headers[k] = redacted
+		}
+	}
+	out.LEDs.Controllers = make([]ControllerConfig, len(c.LEDs.Controllers))
+	for i, ctrl := range c.LEDs.Controllers {
+		ctrl.Address = redactURL(ctrl.Address)
+		if ctrl.MQTT.Password != "" {
+			ctrl.MQTT.Password = redacted
+		}
+		out.LEDs.Controllers[i] = ctrl
+	}
+	out.Scale.MQTT.Broker = redactURL(c.Scale.MQTT.Broker)
+	if c.Scale.MQTT.Password != "" {
+		out.Scale.MQTT.Password = redacted
+	}
+	return out
+}
+
+// String renders the redacted configuration as YAML.
+func (c Config) String() string {
+	raw, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("<unprintable config: %v>", err)
+	}
+	return string(raw)
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// SpoolmanService returns the settings for spoolman.NewService.
+func (c Config) SpoolmanService() spoolman.Config {
+	return spoolman.Config{
+		BaseURL: c.Spoolman.URL,
+		Timeout: c.Spoolman.Timeout,
+		Headers: c.Spoolman.Headers,
+		TLS: spoolman.TLSConfig{
+			CAFile:             c.Spoolman.TLS.CAFile,
+			CertFile:           c.Spoolman.TLS.CertFile,
+			KeyFile:            c.Spoolman.TLS.KeyFile,
+			InsecureSkipVerify: c.Spoolman.TLS.InsecureSkipVerify,
+		},
+	}
+}
+
+// Layout builds the chamber layout. The configuration must have been validated.
+func (c Config) Layout() (*layout.Layout, error) {
+	return layout.New(c.Chambers)
+}
+
+// SimulatorAddress is the base URL of the built-in simulator standing in for
+// the named controller.
+func (c Config) SimulatorAddress(controller string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/simulator/%s", c.Server.Port, url.PathEscape(controller))
+}
+
+// TransferEvents returns the REST Proxy settings and topic for transfers.
+func (c Config) TransferEvents() (events.Config, string) {
+	base, topic, _ := events.ParseTopicURL(c.Transfer.KafkaURL)
+	return events.Config{URL: base, Timeout: c.Transfer.Timeout}, topic
+}
+
+// TransferResults returns the REST Proxy settings and topic transfer
+// outcomes are consumed from. ok is false when transfer.results.url is not
+// set.
+func (c Config) TransferResults() (cfg events.Config, topic string, ok bool) {
+	if c.Transfer.Results.URL == "" {
+		return events.Config{}, "", false
+	}
+	base, topic, _ := events.ParseTopicURL(c.Transfer.Results.URL)
+	return events.Config{URL: base, Timeout: c.Transfer.Timeout}, topic, true
+}
+
+// OutboxOptions returns the settings for outbox.New.
+func (c Config) OutboxOptions() outbox.Options {
+	return outbox.Options{
+		MaxAttempts:    c.Transfer.Outbox.MaxAttempts,
+		MinBackoff:     c.Transfer.Outbox.MinBackoff,
+		MaxBackoff:     c.Transfer.Outbox.MaxBackoff,
+		AttemptTimeout: c.Transfer.Timeout,
+		Retention:      c.Transfer.Outbox.Retention,
+	}
+}
+
+// ScaleOptions returns the settings for scale.New.
+func (c Config) ScaleOptions() scale.Options {
+	return scale.Options{
+		Window:      c.Scale.Window,
+		Tolerance:   c.Scale.Tolerance,
+		Settle:      c.Scale.Settle,
+		MinWeight:   c.Scale.MinWeight,
+		ScanTimeout: c.Scale.ScanTimeout,
+	}
+}
+
+// ScaleMQTT returns the broker settings scale readings are read from. ok is
+// false when scale.mqtt.broker is not set.
+func (c Config) ScaleMQTT() (cfg scale.MQTTConfig, ok bool) {
+	if c.Scale.MQTT.Broker == "" {
+		return scale.MQTTConfig{}, false
+	}
+	return scale.MQTTConfig{
+		Broker:   c.Scale.MQTT.Broker,
+		Topic:    c.Scale.MQTT.Topic,
+		ClientID: c.Scale.MQTT.ClientID,
+		Username: c.Scale.MQTT.Username,
+		Password: c.Scale.MQTT.Password,
+		QoS:      c.Scale.MQTT.QoS,
+	}, true
+}
+
+// LEDServers returns the settings for manager.NewManager, wiring each
+// chamber LED strip to its controller. With leds.simulate every controller
+// is replaced by the built-in simulator.
+func (c Config) LEDServers(l *layout.Layout) []manager.ServerConfig {
+	servers := []manager.ServerConfig{}
+	for _, ctrl := range c.LEDs.Controllers {
+		if c.LEDs.Simulate {
+			ctrl.Driver = manager.DriverHTTP
+			ctrl.Address = c.SimulatorAddress(ctrl.Name)
+		}
+		server := manager.ServerConfig{
+			Address: ctrl.Address,
+			Driver:  ctrl.Driver,
+			Timeout: ctrl.Timeout,
+			MQTT: manager.MQTTConfig{
+				Topic:    ctrl.MQTT.Topic,
+				ClientID: ctrl.MQTT.ClientID,
+				Username: ctrl.MQTT.Username,
+				Password: ctrl.MQTT.Password,
+				QoS:      ctrl.MQTT.QoS,
+				Retain:   ctrl.MQTT.Retain,
+			},
+		}
+		for _, strip := range l.Strips() {
+			if strip.Controller != ctrl.Name {
+				continue
+			}
+			server.PINs = append(server.PINs, manager.PINConfig{
+				Pin:            strip.Pin,
+				LEDs:           strip.LEDs,
+				AddEmptyBefore: strip.EmptyBefore,
+			})
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
@@ -0,0 +1,157 @@
+// Package events talks to Kafka through the Confluent REST Proxy (API v2).
+// It produces JSON records in batches, keyed for partitioning, and consumes
+// topics as a member of a consumer group.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// REST Proxy v2 content types.
+const (
+	contentTypeJSON = "application/vnd.kafka.json.v2+json"
+	contentTypeV2   = "application/vnd.kafka.v2+json"
+)
+
+// DefaultTimeout bounds each request to the proxy when Config.Timeout is zero.
+const DefaultTimeout = 10 * time.Second
+
+// Config describes how to reach a REST Proxy.
+type Config struct {
+	// URL is the proxy root, e.g. "https://kafka.example.com".
+	URL     string
+	Timeout time.Duration
+}
+
+// Client is a REST Proxy client.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// ParseTopicURL splits a topic URL such as
+// "https://kafka.example.com/topics/my-topic" into the proxy root and the
+// topic name.
+func ParseTopicURL(raw string) (base, topic string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", err
+	}
+	prefix, name, ok := strings.Cut(u.Path, "/topics/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("%q is not a REST Proxy topic URL (.../topics/<name>)", raw)
+	}
+	u.Path = prefix
+	u.RawPath = ""
+	return strings.TrimRight(u.String(), "/"), name, nil
+}
+
+// Error is an error response from the proxy, or a per-record produce error
+// (StatusCode 0) inside a successful response.
+type Error struct {
+	StatusCode int
+	// Code is the proxy's error_code, e.g. 40401 for an unknown topic.
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("kafka rejected the record: %s (error code %d)", e.Message, e.Code)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("kafka rest proxy returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("kafka rest proxy returned HTTP %d: %s (error code %d)", e.StatusCode, e.Message, e.Code)
+}
+
+// Temporary reports whether retrying the request may succeed.
+func (e *Error) Temporary() bool {
+	if e.StatusCode == 0 {
+		// Produce error code 2 is a retriable Kafka exception, 1 is not.
+		return e.Code == 2
+	}
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsTemporary reports whether err may go away on retry. Network errors and
+// server errors are temporary, rejected requests are not.
+func IsTemporary(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Temporary()
+	}
+	return err != nil
+}
+
+// do sends a request to the proxy and decodes the JSON response into out,
+// which may be nil.
+func (c *Client) do(ctx context.Context, method, url, contentType, accept string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading kafka rest proxy response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(raw, e) != nil {
+			e.Message = strings.TrimSpace(string(raw))
+		}
+		return e
+	}
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decoding kafka rest proxy response: %w", err)
+	}
+	return nil
+}
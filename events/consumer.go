@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Message is a record read by a consumer.
+type Message struct {
+	Topic     string          `json:"topic"`
+	Key       json.RawMessage `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	Partition int             `json:"partition"`
+	Offset    int64           `json:"offset"`
+}
+
+// ConsumerOptions configure a consumer instance.
+type ConsumerOptions struct {
+	// Name of the instance within the group. The proxy picks one if empty.
+	Name string
+	// OffsetReset is where a new group starts reading: "earliest" or
+	// "latest" (default).
+	OffsetReset string
+	// AutoCommit commits offsets as records are fetched. When false, call
+	// Commit after processing.
+	AutoCommit bool
+}
+
+// Consumer is a consumer group member created on the proxy. Close it to
+// leave the group; the proxy drops idle instances after a while otherwise.
+type Consumer struct {
+	client *Client
+	group  string
+	id     string
+	// base is the instance URL. It is built from the client URL rather than
+	// the base_uri the proxy returns, which may use an internal host name.
+	base string
+}
+
+type createConsumerRequest struct {
+	Name        string `json:"name,omitempty"`
+	Format      string `json:"format"`
+	OffsetReset string `json:"auto.offset.reset,omitempty"`
+	AutoCommit  string `json:"auto.commit.enable"`
+}
+
+type createConsumerResponse struct {
+	InstanceID string `json:"instance_id"`
+	BaseURI    string `json:"base_uri"`
+}
+
+// NewConsumer joins a consumer group. Records are read as JSON.
+func (c *Client) NewConsumer(ctx context.Context, group string, opts ConsumerOptions) (*Consumer, error) {
+	var rsp createConsumerResponse
+	err := c.do(ctx, "POST", c.baseURL+"/consumers/"+url.PathEscape(group), contentTypeV2, contentTypeV2, createConsumerRequest{
+		Name:        opts.Name,
+		Format:      "json",
+		OffsetReset: opts.OffsetReset,
+		AutoCommit:  fmt.Sprintf("%t", opts.AutoCommit),
+	}, &rsp)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.InstanceID == "" {
+		return nil, fmt.Errorf("kafka rest proxy did not return a consumer instance id")
+	}
+	return &Consumer{
+		client: c,
+		group:  group,
+		id:     rsp.InstanceID,
+		base:   c.baseURL + "/consumers/" + url.PathEscape(group) + "/instances/" + url.PathEscape(rsp.InstanceID),
+	}, nil
+}
+
+// ID is the consumer instance ID assigned by the proxy.
+func (c *Consumer) ID() string {
+	return c.id
+}
+
+// Subscribe replaces the consumer's topic subscription.
+func (c *Consumer) Subscribe(ctx context.Context, topics ...string) error {
+	return c.client.do(ctx, "POST", c.base+"/subscription", contentTypeV2, contentTypeV2, map[string][]string{"topics": topics}, nil)
+}
+
+// Poll fetches the next records, waiting up to timeout on the proxy side for
+// new ones. An empty result means nothing arrived in time.
+func (c *Consumer) Poll(ctx context.Context, timeout time.Duration) ([]Message, error) {
+	var messages []Message
+	u := fmt.Sprintf("%s/records?timeout=%d", c.base, timeout.Milliseconds())
+	if err := c.client.do(ctx, "GET", u, "", contentTypeJSON, nil, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+type commitOffset struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// Commit marks messages as processed, so the group resumes after them.
+func (c *Consumer) Commit(ctx context.Context, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	// Commit the highest offset per partition.
+	type partition struct {
+		topic string
+		n     int
+	}
+	highest := map[partition]int64{}
+	for _, m := range messages {
+		key := partition{m.Topic, m.Partition}
+		if off, ok := highest[key]; !ok || m.Offset > off {
+			highest[key] = m.Offset
+		}
+	}
+	offsets := make([]commitOffset, 0, len(highest))
+	for p, off := range highest {
+		offsets = append(offsets, commitOffset{Topic: p.topic, Partition: p.n, Offset: off})
+	}
+	return c.client.do(ctx, "POST", c.base+"/offsets", contentTypeV2, contentTypeV2, map[string][]commitOffset{"offsets": offsets}, nil)
+}
+
+// Close removes the consumer instance from the group.
+func (c *Consumer) Close(ctx context.Context) error {
+	return c.client.do(ctx, "DELETE", c.base, contentTypeV2, contentTypeV2, nil, nil)
+}
@@ -0,0 +1,214 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Record is one message to produce. Records with the same key land on the
+// same partition, which keeps their order.
+type Record struct {
+	Key   any `json:"key,omitempty"`
+	Value any `json:"value"`
+	// Partition overrides key based partitioning.
+	Partition *int `json:"partition,omitempty"`
+}
+
+// Offset is where a produced record was written, or why it was not.
+type Offset struct {
+	Topic     string  `json:"topic"`
+	Partition int     `json:"partition"`
+	Offset    int64   `json:"offset"`
+	ErrorCode *int    `json:"error_code,omitempty"`
+	Error     *string `json:"error,omitempty"`
+}
+
+// Err returns the per-record error reported by the proxy, if any.
+func (o Offset) Err() error {
+	if o.ErrorCode == nil && o.Error == nil {
+		return nil
+	}
+	e := &Error{}
+	if o.ErrorCode != nil {
+		e.Code = *o.ErrorCode
+	}
+	if o.Error != nil {
+		e.Message = *o.Error
+	}
+	return e
+}
+
+type produceRequest struct {
+	Records []Record `json:"records"`
+}
+
+type produceResponse struct {
+	Offsets []Offset `json:"offsets"`
+}
+
+// Produce writes records to topic in one request and returns their offsets
+// in record order. Check Offset.Err for records the broker rejected.
+func (c *Client) Produce(ctx context.Context, topic string, records []Record) ([]Offset, error) {
+	var rsp produceResponse
+	u := c.baseURL + "/topics/" + url.PathEscape(topic)
+	if err := c.do(ctx, "POST", u, contentTypeJSON, contentTypeV2, produceRequest{Records: records}, &rsp); err != nil {
+		return nil, err
+	}
+	if len(rsp.Offsets) != len(records) {
+		return nil, fmt.Errorf("kafka rest proxy returned %d offsets for %d records", len(rsp.Offsets), len(records))
+	}
+	for i := range rsp.Offsets {
+		rsp.Offsets[i].Topic = topic
+	}
+	return rsp.Offsets, nil
+}
+
+// Producer batches records sent concurrently to one topic into a single
+// produce request.
+type Producer struct {
+	client *Client
+	topic  string
+	opts   ProducerOptions
+	queue  chan *pendingRecord
+}
+
+// ProducerOptions trade latency for fewer proxy requests. Unset fields use
+// DefaultMaxBatch and DefaultLinger.
+type ProducerOptions struct {
+	// MaxBatch is the most records sent in one request.
+	MaxBatch int
+	// Linger is how long the first record of a batch waits for more.
+	Linger time.Duration
+}
+
+const (
+	DefaultMaxBatch = 100
+	DefaultLinger   = 20 * time.Millisecond
+)
+
+type pendingRecord struct {
+	ctx    context.Context
+	record Record
+	result chan produceResult
+}
+
+type produceResult struct {
+	offset Offset
+	err    error
+}
+
+func (c *Client) NewProducer(topic string, opts ProducerOptions) *Producer {
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = DefaultMaxBatch
+	}
+	if opts.Linger <= 0 {
+		opts.Linger = DefaultLinger
+	}
+	return &Producer{
+		client: c,
+		topic:  topic,
+		opts:   opts,
+		queue:  make(chan *pendingRecord, opts.MaxBatch),
+	}
+}
+
+// Topic is the topic the producer writes to.
+func (p *Producer) Topic() string {
+	return p.topic
+}
+
+// Send queues a record for the next batch and waits until it is written.
+// Run must be running.
+func (p *Producer) Send(ctx context.Context, record Record) (Offset, error) {
+	pending := &pendingRecord{ctx: ctx, record: record, result: make(chan produceResult, 1)}
+	select {
+	case p.queue <- pending:
+	case <-ctx.Done():
+		return Offset{}, ctx.Err()
+	}
+	select {
+	case res := <-pending.result:
+		return res.offset, res.err
+	case <-ctx.Done():
+		return Offset{}, ctx.Err()
+	}
+}
+
+// Run collects queued records into batches and produces them until ctx is
+// cancelled.
+func (p *Producer) Run(ctx context.Context) {
+	for {
+		var batch []*pendingRecord
+		select {
+		case <-ctx.Done():
+			return
+		case first := <-p.queue:
+			batch = append(batch, first)
+		}
+
+		linger := time.NewTimer(p.opts.Linger)
+	collect:
+		for len(batch) < p.opts.MaxBatch {
+			select {
+			case next := <-p.queue:
+				batch = append(batch, next)
+			case <-linger.C:
+				break collect
+			case <-ctx.Done():
+				break collect
+			}
+		}
+		linger.Stop()
+
+		p.flush(ctx, batch)
+	}
+}
+
+// flush produces a batch, skipping records whose sender already gave up.
+func (p *Producer) flush(ctx context.Context, batch []*pendingRecord) {
+	live := batch[:0]
+	for _, pending := range batch {
+		if pending.ctx.Err() == nil {
+			live = append(live, pending)
+		}
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	// The request outlives a cancelled Run so the batch is not lost, and it
+	// gets the longest deadline any sender allows (bounded by the client
+	// timeout when a sender has none).
+	reqCtx := context.WithoutCancel(ctx)
+	var deadline time.Time
+	for _, pending := range live {
+		d, ok := pending.ctx.Deadline()
+		if !ok {
+			deadline = time.Time{}
+			break
+		}
+		if d.After(deadline) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithDeadline(reqCtx, deadline)
+		defer cancel()
+	}
+
+	records := make([]Record, len(live))
+	for i, pending := range live {
+		records[i] = pending.record
+	}
+	offsets, err := p.client.Produce(reqCtx, p.topic, records)
+	for i, pending := range live {
+		if err != nil {
+			pending.result <- produceResult{err: err}
+			continue
+		}
+		pending.result <- produceResult{offset: offsets[i], err: offsets[i].Err()}
+	}
+}
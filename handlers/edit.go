@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// SpoolUpdateRequest is the body of PATCH /api/spool/{id}. Omitted fields
+// are left as they are and empty text clears a field.
+type SpoolUpdateRequest struct {
+	Location *string `json:"location"`
+	LotNr    *string `json:"lot_nr"`
+	Comment  *string `json:"comment"`
+	// Price is a number, or null to clear it.
+	Price    json.RawMessage `json:"price"`
+	Archived *bool           `json:"archived"`
+}
+
+// SpoolUseRequest is the body of POST /api/spool/{id}/use: the filament
+// used, either as a length in millimeters or as a weight in grams.
+type SpoolUseRequest struct {
+	Length float32 `json:"length"`
+	Weight float32 `json:"weight"`
+}
+
+// UpdateSpoolHandler edits the location, lot number, comment, price or
+// archived state of a spool (PATCH /api/spool/{id}) and answers with the
+// updated spool.
+func (h *Handler) UpdateSpoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := spoolPathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SpoolUpdateRequest
+	if !decodeStrict(w, r, &req) {
+		return
+	}
+	update := spoolman.SpoolUpdate{
+		Location: req.Location,
+		LotNr:    req.LotNr,
+		Comment:  req.Comment,
+		Archived: req.Archived,
+	}
+	if len(req.Price) > 0 {
+		if string(req.Price) == "null" {
+			update.ClearPrice = true
+		} else {
+			var price float32
+			if err := json.Unmarshal(req.Price, &price); err != nil {
+				writeAPIError(w, http.StatusUnprocessableEntity, apiError{
+					Error:   "invalid_update",
+					Message: "The spool update is not valid",
+					Fields:  []fieldError{{Field: "price", Message: "must be a number or null"}},
+				})
+				return
+			}
+			update.Price = &price
+		}
+	}
+
+	spool, err := h.spoolman.UpdateSpool(r.Context(), id, update)
+	if err != nil {
+		writeSpoolWriteError(w, id, "update", err)
+		return
+	}
+	h.spoolWritten(w, spool)
+}
+
+// UseSpoolHandler records filament used from a spool (POST
+// /api/spool/{id}/use) and answers with the updated spool.
+func (h *Handler) UseSpoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := spoolPathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SpoolUseRequest
+	if !decodeStrict(w, r, &req) {
+		return
+	}
+
+	spool, err := h.spoolman.UseSpool(r.Context(), id, spoolman.SpoolUse{Length: req.Length, Weight: req.Weight})
+	if err != nil {
+		writeSpoolWriteError(w, id, "use", err)
+		return
+	}
+	h.spoolWritten(w, spool)
+}
+
+// spoolPathID parses the {id} path value, answering 404 if it is not a
+// spool ID.
+func spoolPathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeAPIError(w, http.StatusNotFound, apiError{Error: "unknown_spool", Message: "Not a spool ID: " + r.PathValue("id")})
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeStrict decodes a JSON request body into v, rejecting unknown
+// fields so typos are not silently ignored.
+func decodeStrict(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_request", Message: "Error reading request body"})
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_json", Message: "Invalid request: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// writeSpoolWriteError answers a failed spool write: rejected values with
+// the fields at fault, an unknown spool with 404 and Spoolman failures with
+// 502. action is "update" or "use".
+func writeSpoolWriteError(w http.ResponseWriter, id int, action string, err error) {
+	if fields := spoolman.FieldErrors(err); len(fields) > 0 {
+		out := make([]fieldError, len(fields))
+		for i, f := range fields {
+			out[i] = fieldError{Field: f.Field, Message: f.Message}
+		}
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{Error: "invalid_" + action, Message: "The spool " + action + " is not valid", Fields: out})
+		return
+	}
+	switch {
+	case errors.Is(err, spoolman.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, apiError{Error: "unknown_spool", Message: "Spool #" + strconv.Itoa(id) + " does not exist"})
+	case errors.Is(err, spoolman.ErrRejected):
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{Error: action + "_rejected", Message: err.Error()})
+	default:
+		log.Printf("Error writing spool %d: %+v", id, err)
+		writeAPIError(w, http.StatusBadGateway, apiError{Error: "spoolman_unavailable", Message: "Could not update the spool in Spoolman: " + err.Error()})
+	}
+}
+
+// spoolWritten refreshes after a spool write, then answers with the
+// updated spool.
+func (h *Handler) spoolWritten(w http.ResponseWriter, spool *spoolman.Spool) {
+	h.spoolChanged(spool)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newSpoolResponse(spool)); err != nil {
+		log.Printf("Error encoding spool: %v", err)
+	}
+}
+
+// spoolChanged refreshes the spool list and other browsers after a spool
+// was written.
+func (h *Handler) spoolChanged(spool *spoolman.Spool) {
+	h.spools.Invalidate()
+	location := ""
+	if spool.Location != nil {
+		location = spoolman.GetSpoolLocation(*spool)
+	}
+	h.live.Publish(hub.TypeSpool, hub.SpoolChange{ID: spool.Id, Location: location, Change: "updated"})
+}
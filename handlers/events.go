@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tryy3/filament-chamber/hub"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment, so proxies
+// don't close it.
+const sseKeepAlive = 25 * time.Second
+
+// EventsHandler streams live updates as Server-Sent Events
+// (GET /api/events): "spool", "filament" and "vendor" when one changed,
+// "transfer" when a transfer was initiated or finished, "leds" when a
+// controller pin changed, "sensor" for chamber sensor readings, "scale" when
+// the weight on a scale changed and "weighing" when a scanned spool was
+// weighed. The data of every event is JSON. The current LED, sensor and
+// scale state is sent first.
+func (h *Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Event stream not supported: %v", err)
+		return
+	}
+
+	events, unsubscribe := h.live.Subscribe()
+	defer unsubscribe()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e := <-events:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				log.Printf("Error encoding %s event: %v", e.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// SensorReading is one reading of a chamber sensor. Values the sensor does
+// not measure are left out.
+type SensorReading struct {
+	Sensor      string    `json:"sensor"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Humidity    *float64  `json:"humidity,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// SensorHandler accepts a chamber sensor reading (POST /api/sensors) and
+// sends it to the live event stream.
+func (h *Handler) SensorHandler(w http.ResponseWriter, r *http.Request) {
+	var reading SensorReading
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&reading); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_json", Message: "Invalid sensor reading: " + err.Error()})
+		return
+	}
+
+	var fields []fieldError
+	reading.Sensor = strings.TrimSpace(reading.Sensor)
+	if reading.Sensor == "" {
+		fields = append(fields, fieldError{Field: "sensor", Message: "is required"})
+	}
+	if reading.Temperature == nil && reading.Humidity == nil {
+		fields = append(fields, fieldError{Field: "temperature", Message: "temperature or humidity is required"})
+	}
+	if reading.Humidity != nil && (*reading.Humidity < 0 || *reading.Humidity > 100) {
+		fields = append(fields, fieldError{Field: "humidity", Message: fmt.Sprintf("must be between 0 and 100, got %g", *reading.Humidity)})
+	}
+	if len(fields) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{Error: "invalid_reading", Message: "The sensor reading is not valid", Fields: fields})
+		return
+	}
+	if reading.Time.IsZero() {
+		reading.Time = time.Now()
+	}
+
+	h.live.Retain(hub.TypeSensor, reading.Sensor, reading)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// HandleSpoolmanEvent forwards a change from Spoolman's change feed to the
+// browsers. A spool showing up at the target of a pending transfer
+// completes that transfer.
+func (h *Handler) HandleSpoolmanEvent(e spoolman.Event) {
+	h.spools.Invalidate()
+	switch e := e.(type) {
+	case spoolman.SpoolEvent:
+		location := ""
+		if e.Payload.Location != nil {
+			location = spoolman.GetSpoolLocation(e.Payload)
+		}
+		h.live.Publish(hub.TypeSpool, hub.SpoolChange{ID: e.Payload.Id, Location: location, Change: string(e.Type)})
+		if e.Type == spoolman.Updated && location != "" {
+			if eventID, ok := h.transfers.ResolveMatch(e.Payload.Id, location, nil); ok {
+				log.Printf("Transfer %s completed: spool #%d is at %s", eventID, e.Payload.Id, location)
+			}
+		}
+	case spoolman.FilamentEvent:
+		h.live.Publish(hub.TypeFilament, hub.EntityChange{ID: e.Payload.Id, Change: string(e.Type)})
+	case spoolman.VendorEvent:
+		h.live.Publish(hub.TypeVendor, hub.EntityChange{ID: e.Payload.Id, Change: string(e.Type)})
+	}
+}
+
+// SpoolmanReconnected drops cached spools and tells the browsers to reload,
+// as changes made while the change feed was down are not replayed.
+func (h *Handler) SpoolmanReconnected() {
+	h.spools.Invalidate()
+	h.live.Publish(hub.TypeSpool, hub.SpoolChange{})
+}
@@ -1,23 +1,115 @@
 package handlers
 
 import (
-	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/tryy3/filament-chamber/colors"
+	"github.com/tryy3/filament-chamber/events"
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/layout"
+	"github.com/tryy3/filament-chamber/manager"
+	"github.com/tryy3/filament-chamber/outbox"
+	"github.com/tryy3/filament-chamber/scale"
+	"github.com/tryy3/filament-chamber/search"
+	"github.com/tryy3/filament-chamber/simulator"
 	"github.com/tryy3/filament-chamber/spoolman"
 	"github.com/tryy3/filament-chamber/templates"
+	"github.com/tryy3/filament-chamber/transfers"
 )
 
+// Transfer modes, see Config.TransferMode.
+const (
+	TransferModeKafka    = "kafka"
+	TransferModeSpoolman = "spoolman"
+)
+
+// Config holds the handler settings that vary per deployment.
+type Config struct {
+	// TransferMode selects how location transfers are applied.
+	TransferMode    string
+	TransferTimeout time.Duration
+	// LocateDuration is how long a located slot stays lit.
+	LocateDuration time.Duration
+	// LocateBlink makes located slots blink unless the request says otherwise.
+	LocateBlink bool
+	// FilterLEDs mirrors spool filter results on the chamber LEDs.
+	FilterLEDs bool
+	// FilterIdleTimeout turns the filter LEDs off after no new filter request.
+	FilterIdleTimeout time.Duration
+	// FilterDim is the brightness factor for occupied slots that don't match.
+	FilterDim float64
+}
+
+// Handler holds the dependencies shared by the HTTP handlers.
+type Handler struct {
+	spoolman *spoolman.Service
+	// spools caches the spool list; invalidate it after changing spools.
+	spools *spoolman.Cache
+	layout *layout.Layout
+	leds   *manager.Manager
+	// sim is the LED controller simulator, nil unless LEDs are simulated.
+	sim *simulator.Simulator
+	// outbox holds location transfers until they are delivered.
+	outbox *outbox.Outbox
+	// transferEvents produces transfers to Kafka, nil unless the transfer
+	// mode is kafka.
+	transferEvents *events.Producer
+	// transfers tracks the outcome of initiated transfers.
+	transfers *transfers.Tracker
+	// live sends updates to the browsers on the event stream.
+	live *hub.Hub
+	// scales pairs scale readings with scanned spools.
+	scales *scale.Monitor
+	cfg    Config
+}
+
+// Deps are the services a Handler works with. Sim is nil when the LED
+// controllers are real, and TransferEvents when transfers do not go through
+// Kafka; everything else is required.
+type Deps struct {
+	Spoolman *spoolman.Service
+	Spools   *spoolman.Cache
+	Layout   *layout.Layout
+	LEDs     *manager.Manager
+	Sim      *simulator.Simulator
+	// Outbox stores location transfers, and Transfers tracks their outcome.
+	Outbox         *outbox.Outbox
+	TransferEvents *events.Producer
+	Transfers      *transfers.Tracker
+	Live           *hub.Hub
+	Scales         *scale.Monitor
+}
+
+// New creates a Handler from its dependencies and settings.
+func New(deps Deps, cfg Config) *Handler {
+	return &Handler{
+		spoolman:       deps.Spoolman,
+		spools:         deps.Spools,
+		layout:         deps.Layout,
+		leds:           deps.LEDs,
+		sim:            deps.Sim,
+		outbox:         deps.Outbox,
+		transferEvents: deps.TransferEvents,
+		transfers:      deps.Transfers,
+		live:           deps.Live,
+		scales:         deps.Scales,
+		cfg:            cfg,
+	}
+}
+
 // HomeHandler serves the home page
-func HomeHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	// Only serve home page for the root path
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -34,10 +126,10 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // SpoolHandler serves the spool management page
-func SpoolHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SpoolHandler(w http.ResponseWriter, r *http.Request) {
 	// Render the spool template
-	materials, brands := GetFilterMetadata()
-	component := templates.Spool(materials, brands)
+	materials, brands := h.GetFilterMetadata(r.Context())
+	component := templates.Spool(materials, brands, h.layout.Chambers())
 	err := component.Render(r.Context(), w)
 	if err != nil {
 		log.Printf("Error rendering template: %+v", err)
@@ -47,7 +139,7 @@ func SpoolHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // SpoolDetailHandler serves the spool detail page (/spool/{id})
-func SpoolDetailHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SpoolDetailHandler(w http.ResponseWriter, r *http.Request) {
 	// Expect: /spool/{id}
 	idStr := strings.TrimPrefix(r.URL.Path, "/spool/")
 	if idStr == "" || strings.Contains(idStr, "/") {
@@ -61,7 +153,11 @@ func SpoolDetailHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	found, err := spoolman.GetSpool(id)
+	found, err := h.spoolman.GetSpool(r.Context(), id)
+	if errors.Is(err, spoolman.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting spool: %+v", err)
 		http.Error(w, "Error getting spool", http.StatusInternalServerError)
@@ -73,7 +169,7 @@ func SpoolDetailHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	component := templates.SpoolDetail(found)
+	component := templates.SpoolDetail(found, h.spoolman.BaseURL())
 	if err := component.Render(r.Context(), w); err != nil {
 		log.Printf("Error rendering template: %+v", err)
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
@@ -98,6 +194,7 @@ type spoolForOptResponse struct {
 		Material             string  `json:"material"`
 		ColorHex             string  `json:"color_hex"`
 		MultiColorHexes      string  `json:"multi_color_hexes"`
+		ColorName            string  `json:"color_name"`
 		Density              float32 `json:"density"`
 		Diameter             float32 `json:"diameter"`
 		Weight               float32 `json:"weight"`
@@ -108,7 +205,7 @@ type spoolForOptResponse struct {
 }
 
 // SpoolJSONHandler returns a frontend-friendly view of a spool (for OPT mapping).
-func SpoolJSONHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SpoolJSONHandler(w http.ResponseWriter, r *http.Request) {
 	// Expect: /api/spool/{id}
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/spool/")
 	if idStr == "" || strings.Contains(idStr, "/") {
@@ -121,7 +218,11 @@ func SpoolJSONHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	spool, err := spoolman.GetSpool(id)
+	spool, err := h.spoolman.GetSpool(r.Context(), id)
+	if errors.Is(err, spoolman.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting spool: %+v", err)
 		http.Error(w, "Error getting spool", http.StatusInternalServerError)
@@ -132,6 +233,15 @@ func SpoolJSONHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newSpoolResponse(spool)); err != nil {
+		http.Error(w, "Error encoding spool", http.StatusInternalServerError)
+		return
+	}
+}
+
+// newSpoolResponse builds the frontend-friendly view of a spool.
+func newSpoolResponse(spool *spoolman.Spool) spoolForOptResponse {
 	out := spoolForOptResponse{
 		Id:              spool.Id,
 		Archived:        spool.Archived,
@@ -149,23 +259,19 @@ func SpoolJSONHandler(w http.ResponseWriter, r *http.Request) {
 	out.Filament.Material = spoolman.GetFilamentMaterial(spool.Filament)
 	out.Filament.ColorHex = spoolman.GetFilamentColorHex(spool.Filament)
 	out.Filament.MultiColorHexes = spoolman.GetFilamentMultiColorHexes(spool.Filament)
+	out.Filament.ColorName = spoolman.GetFilamentColorName(spool.Filament)
 	out.Filament.Density = spool.Filament.Density
 	out.Filament.Diameter = spool.Filament.Diameter
 	out.Filament.Weight = spoolman.GetFilamentWeight(spool.Filament)
 	out.Filament.SpoolWeight = spoolman.GetFilamentSpoolWeight(spool.Filament)
 	out.Filament.SettingsExtruderTemp = spoolman.GetFilamentSettingsExtruderTemp(spool.Filament)
 	out.Filament.SettingsBedTemp = spoolman.GetFilamentSettingsBedTemp(spool.Filament)
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(out); err != nil {
-		http.Error(w, "Error encoding spool", http.StatusInternalServerError)
-		return
-	}
+	return out
 }
 
 // AdminHandler serves the admin/testing tools page
-func AdminHandler(w http.ResponseWriter, r *http.Request) {
-	component := templates.Admin()
+func (h *Handler) AdminHandler(w http.ResponseWriter, r *http.Request) {
+	component := templates.Admin(h.layout.Chambers(), h.sim != nil)
 	err := component.Render(r.Context(), w)
 	if err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
@@ -174,7 +280,7 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // DemoHandler is an example HTMX endpoint that returns HTML
-func DemoHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DemoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
 	currentTime := time.Now().Format("15:04:05")
 	html := fmt.Sprintf(`
@@ -187,35 +293,180 @@ func DemoHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, html)
 }
 
-var (
-	chamberLocationRegex = regexp.MustCompile(`^chamber(?:\d+)_([A-Z0-9]+)$`)
-)
-
 // SpoolFilters contains filter criteria for spools
 type SpoolFilters struct {
+	// Search is free text matched against names, vendor, material, lot,
+	// location, comments and extra fields
+	Search   string
 	Material string
 	Brand    string
-	Color    string
-	SpoolID  string
+	// Color is a hex color. A complete color matches spools within
+	// Tolerance (ΔE2000) of it; a partial one matches hex substrings.
+	Color     string
+	Tolerance float64
+	SpoolID   string
+	// Chamber limits results to spools located in the chamber with this ID
+	Chamber string
+	// Location and Lot are search terms for the spool location and lot
+	// number, matched the way Spoolman matches them
+	Location string
+	Lot      string
+	// Archived includes archived spools in the list
+	Archived bool
+	// Sort is "field:asc" or "field:desc" for a field in spoolSorts, empty
+	// for Spoolman's order
+	Sort string
+	// Page is the 1-based page of the list, with PerPage spools per page
+	Page    int
+	PerPage int
+
+	// terms and color are Search and a complete Color, parsed
+	terms search.Query
+	color *colors.Lab
 }
 
+// List paging: spools per page unless the request asks otherwise, and the
+// most it may ask for.
+const (
+	defaultPerPage = 24
+	maxPerPage     = 100
+)
+
+// Color matching tolerance (ΔE2000) unless the request asks otherwise, and
+// the most it may ask for.
+const (
+	defaultTolerance = 15
+	maxTolerance     = 100
+)
+
 // isEmpty checks if all filters are empty
 func (f SpoolFilters) isEmpty() bool {
-	return f.Material == "" && f.Brand == "" && f.Color == "" && f.SpoolID == ""
+	return f.Search == "" && f.Material == "" && f.Brand == "" && f.Color == "" && f.SpoolID == "" && f.Chamber == "" &&
+		f.Location == "" && f.Lot == ""
 }
 
 // parseFiltersFromRequest extracts filter parameters from the HTTP request
 func parseFiltersFromRequest(r *http.Request) SpoolFilters {
-	return SpoolFilters{
-		Material: strings.TrimSpace(r.URL.Query().Get("material")),
-		Brand:    strings.TrimSpace(r.URL.Query().Get("brand")),
-		Color:    strings.TrimSpace(r.URL.Query().Get("color")),
-		SpoolID:  strings.TrimSpace(r.URL.Query().Get("spool_id")),
+	query := r.URL.Query()
+	filters := SpoolFilters{
+		Search:    strings.TrimSpace(query.Get("q")),
+		Material:  strings.TrimSpace(query.Get("material")),
+		Brand:     strings.TrimSpace(query.Get("brand")),
+		Color:     strings.TrimSpace(query.Get("color")),
+		Tolerance: defaultTolerance,
+		SpoolID:   strings.TrimSpace(query.Get("spool_id")),
+		Chamber:   strings.TrimSpace(query.Get("chamber")),
+		Location:  strings.TrimSpace(query.Get("location")),
+		Lot:       strings.TrimSpace(query.Get("lot")),
+		Page:      1,
+		PerPage:   defaultPerPage,
+	}
+	filters.terms = search.Parse(filters.Search)
+	if c, err := colors.ParseHex(filters.Color); err == nil {
+		lab := c.Lab()
+		filters.color = &lab
+	}
+	if tolerance, err := strconv.ParseFloat(query.Get("tolerance"), 64); err == nil && tolerance >= 0 {
+		filters.Tolerance = min(tolerance, maxTolerance)
+	}
+	// A checkbox sends "on"
+	archived := query.Get("archived")
+	filters.Archived = archived == "on" || archived == "1" || strings.EqualFold(archived, "true")
+	if _, _, ok := parseSort(query.Get("sort")); ok {
+		filters.Sort = query.Get("sort")
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 1 {
+		filters.Page = page
+	}
+	if perPage, err := strconv.Atoi(query.Get("per_page")); err == nil && perPage > 0 {
+		filters.PerPage = min(perPage, maxPerPage)
+	}
+	return filters
+}
+
+// spoolmanQuery translates the filters for Spoolman. ok is false when a
+// filter Spoolman cannot apply is set, so the list has to be filtered
+// locally.
+func (f SpoolFilters) spoolmanQuery() (q spoolman.SpoolQuery, ok bool) {
+	q = spoolman.SpoolQuery{
+		Location: f.Location,
+		LotNr:    f.Lot,
+		Archived: f.Archived,
+		Sort:     f.Sort,
+		Limit:    f.PerPage,
+		Offset:   (f.Page - 1) * f.PerPage,
+	}
+	if f.Material != "" {
+		q.Material = spoolman.Exact(f.Material)
+	}
+	if f.Brand != "" {
+		q.Vendor = spoolman.Exact(f.Brand)
+	}
+	return q, f.Search == "" && f.Color == "" && f.SpoolID == "" && f.Chamber == ""
+}
+
+// spoolSorts compares spools by each field the list can be sorted by, for
+// sorting locally.
+var spoolSorts = map[string]func(a, b spoolman.Spool) int{
+	spoolman.SortID: func(a, b spoolman.Spool) int {
+		return cmp.Compare(a.Id, b.Id)
+	},
+	spoolman.SortFilamentName: func(a, b spoolman.Spool) int {
+		return compareFold(spoolman.GetFilamentName(a.Filament), spoolman.GetFilamentName(b.Filament))
+	},
+	spoolman.SortMaterial: func(a, b spoolman.Spool) int {
+		return compareFold(spoolman.GetFilamentMaterial(a.Filament), spoolman.GetFilamentMaterial(b.Filament))
+	},
+	spoolman.SortVendor: func(a, b spoolman.Spool) int {
+		return compareFold(spoolman.GetFilamentBrand(a.Filament), spoolman.GetFilamentBrand(b.Filament))
+	},
+	spoolman.SortLocation: func(a, b spoolman.Spool) int {
+		return compareFold(spoolman.GetSpoolLocation(a), spoolman.GetSpoolLocation(b))
+	},
+	spoolman.SortRemainingWeight: func(a, b spoolman.Spool) int {
+		return cmp.Compare(spoolman.GetSpoolRemainingWeight(a), spoolman.GetSpoolRemainingWeight(b))
+	},
+	spoolman.SortRegistered: func(a, b spoolman.Spool) int {
+		return cmp.Compare(a.Registered, b.Registered)
+	},
+}
+
+func compareFold(a, b string) int {
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// parseSort splits a "field:direction" sort into its field and whether it
+// is descending.
+func parseSort(s string) (field string, desc bool, ok bool) {
+	field, dir, _ := strings.Cut(s, ":")
+	if _, known := spoolSorts[field]; !known || (dir != "asc" && dir != "desc") {
+		return "", false, false
 	}
+	return field, dir == "desc", true
+}
+
+// sortSpools sorts spools in place by a sort accepted by parseSort. Ties,
+// and an empty sort, keep the order by ID like Spoolman does.
+func sortSpools(spools []spoolman.Spool, order string) {
+	field, desc, ok := parseSort(order)
+	if !ok {
+		field = spoolman.SortID
+	}
+	compare := spoolSorts[field]
+	slices.SortStableFunc(spools, func(a, b spoolman.Spool) int {
+		c := compare(a, b)
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Id, b.Id)
+		}
+		return c
+	})
 }
 
 // matchesFilters checks if a spool matches the given filter criteria
-func matchesFilters(spool spoolman.Spool, filters SpoolFilters) bool {
+func matchesFilters(spool spoolman.Spool, filters SpoolFilters, l *layout.Layout) bool {
 	// Material filter
 	if filters.Material != "" {
 		material := spoolman.GetFilamentMaterial(spool.Filament)
@@ -232,8 +483,18 @@ func matchesFilters(spool spoolman.Spool, filters SpoolFilters) bool {
 		}
 	}
 
-	// Color filter (case-insensitive substring match on hex color)
-	if filters.Color != "" {
+	// Free-text search, every term has to match
+	if !filters.terms.Empty() && filters.terms.Score(spoolSearchFields(spool)) == 0 {
+		return false
+	}
+
+	// Color filter: perceptual distance to a complete color, otherwise a
+	// case-insensitive substring match on the hex color
+	if filters.color != nil {
+		if d, ok := colorDistance(spool, *filters.color); !ok || d > filters.Tolerance {
+			return false
+		}
+	} else if filters.Color != "" {
 		colorHex := spoolman.GetFilamentColorHex(spool.Filament)
 		filterColor := strings.ToLower(strings.TrimPrefix(filters.Color, "#"))
 		if !strings.Contains(strings.ToLower(colorHex), filterColor) {
@@ -249,26 +510,44 @@ func matchesFilters(spool spoolman.Spool, filters SpoolFilters) bool {
 		}
 	}
 
+	// Location and lot number filters (Spoolman search terms)
+	if filters.Location != "" {
+		location := ""
+		if spool.Location != nil {
+			location = spoolman.GetSpoolLocation(spool)
+		}
+		if !spoolman.MatchesSearch(location, filters.Location) {
+			return false
+		}
+	}
+	if !spoolman.MatchesSearch(spoolman.GetSpoolLotNr(spool), filters.Lot) {
+		return false
+	}
+
+	if spool.Archived && !filters.Archived {
+		return false
+	}
+
+	// Chamber filter (spool must be in one of the chamber's slots)
+	if filters.Chamber != "" {
+		slot, ok := l.ParseLocation(spoolman.GetSpoolLocation(spool))
+		if !ok || slot.Chamber.ID != filters.Chamber {
+			return false
+		}
+	}
+
 	return true
 }
 
 // applyFilters filters spools and returns filtered list and ID map
-func applyFilters(spools *[]spoolman.Spool, filters SpoolFilters) (*[]spoolman.Spool, map[int]bool) {
+func applyFilters(spools *[]spoolman.Spool, filters SpoolFilters, l *layout.Layout) (*[]spoolman.Spool, map[int]bool) {
 	filteredIDs := make(map[int]bool)
 
-	// If no filters, return all spools and populate all IDs
-	if filters.isEmpty() {
-		for i := range *spools {
-			filteredIDs[(*spools)[i].Id] = true
-		}
-		return spools, filteredIDs
-	}
-
-	// Apply filters
+	// Even without filters archived spools only show when asked for
 	filtered := []spoolman.Spool{}
 	for i := range *spools {
 		spool := (*spools)[i]
-		if matchesFilters(spool, filters) {
+		if matchesFilters(spool, filters, l) {
 			filtered = append(filtered, spool)
 			filteredIDs[spool.Id] = true
 		}
@@ -283,6 +562,9 @@ func getUniqueMaterials(spools *[]spoolman.Spool) []string {
 	materials := []string{}
 
 	for i := range *spools {
+		if (*spools)[i].Archived {
+			continue
+		}
 		material := spoolman.GetFilamentMaterial((*spools)[i].Filament)
 		if material != "" && material != "Unknown" && !materialSet[material] {
 			materialSet[material] = true
@@ -299,6 +581,9 @@ func getUniqueBrands(spools *[]spoolman.Spool) []string {
 	brands := []string{}
 
 	for i := range *spools {
+		if (*spools)[i].Archived {
+			continue
+		}
 		brand := spoolman.GetFilamentBrand((*spools)[i].Filament)
 		if brand != "" && brand != "Unknown Brand" && !brandSet[brand] {
 			brandSet[brand] = true
@@ -310,7 +595,7 @@ func getUniqueBrands(spools *[]spoolman.Spool) []string {
 }
 
 // SpoolsAPIHandler is an HTMX endpoint that returns JSON
-func SpoolsAPIHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SpoolsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Finding spools")
 	w.Header().Set("Content-Type", "application/json")
 
@@ -318,32 +603,72 @@ func SpoolsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	filters := parseFiltersFromRequest(r)
 	log.Printf("Applied filters: %+v", filters)
 
-	// Fetch all spools
-	spools, err := spoolman.FindSpools()
+	// Fetch all spools; the grids show every slot, so they are built from
+	// the whole list
+	snap, err := h.spools.Spools(r.Context())
 	if err != nil {
 		log.Printf("Error finding spools: %+v", err)
 		http.Error(w, "Error finding spools", http.StatusInternalServerError)
 		return
 	}
+	spools := &snap.Spools
 
 	// Apply filters
-	filteredSpools, filteredIDs := applyFilters(spools, filters)
-	log.Printf("Filtered %d spools from %d total", len(*filteredSpools), len(*spools))
-
-	// Create a map for O(1) location lookups
+	_, filteredIDs := applyFilters(spools, filters, h.layout)
+
+	// The list shows one page of the matching spools. Going past the last
+	// page (e.g. after spools were removed) shows the last one instead.
+	page, total := h.spoolPage(r.Context(), filters, snap)
+	pages := max(1, (total+filters.PerPage-1)/filters.PerPage)
+	if filters.Page > pages {
+		filters.Page = pages
+		page, total = h.spoolPage(r.Context(), filters, snap)
+	}
+	log.Printf("Showing %d of %d matching spools (%d total)", len(page), total, len(*spools))
+
+	// Create a map for O(1) location lookups, keyed by the slot's canonical
+	// location so equal slot names in different chambers don't collide.
+	// Spools with a location outside every chamber go to the "elsewhere" list.
+	// Archived spools are in neither, they are no longer on the shelf.
 	spoolsByLocation := make(map[string]*spoolman.Spool)
+	elsewhere := []*spoolman.Spool{}
 	for i := range *spools {
+		if (*spools)[i].Archived {
+			continue
+		}
 		location := spoolman.GetSpoolLocation((*spools)[i])
-		if location != "" && location != "Not specified" {
-			matches := chamberLocationRegex.FindStringSubmatch(location)
-			if len(matches) > 1 {
-				location = matches[1]
-			}
-			spoolsByLocation[location] = &(*spools)[i]
+		if slot, ok := h.layout.ParseLocation(location); ok {
+			spoolsByLocation[slot.Location()] = &(*spools)[i]
+		} else if location != "" && location != "Not specified" {
+			elsewhere = append(elsewhere, &(*spools)[i])
 		}
 	}
+	sort.SliceStable(elsewhere, func(i, j int) bool {
+		return spoolman.GetSpoolLocation(*elsewhere[i]) < spoolman.GetSpoolLocation(*elsewhere[j])
+	})
+
+	// A chamber filter narrows the grid to that chamber
+	chambers := h.layout.Chambers()
+	if filters.Chamber != "" {
+		chambers = nil
+		elsewhere = nil
+		if chamber, ok := h.layout.Chamber(filters.Chamber); ok {
+			chambers = []*layout.Chamber{chamber}
+		}
+	}
+
+	// Only a filter the user applied is shown, not live reloads or paging,
+	// so one browser's reloads don't undo another's filter
+	if h.cfg.FilterLEDs && r.URL.Query().Get("leds") == "1" {
+		go h.showFilterOnLEDs(filters, spoolsByLocation, filteredIDs)
+	}
 
-	component := templates.SpoolsResult(filteredSpools, spoolsByLocation, filteredIDs)
+	if snap.Stale {
+		if err := templates.StaleBanner(snap.Fetched, snap.Err.Error()).Render(r.Context(), w); err != nil {
+			log.Printf("Error rendering template: %+v", err)
+		}
+	}
+	component := templates.SpoolsResult(&page, filters.terms.Terms, spoolsByLocation, filteredIDs, !filters.isEmpty(), chambers, elsewhere, filters.Page, pages, total)
 	err = component.Render(r.Context(), w)
 	if err != nil {
 		log.Printf("Error rendering template: %+v", err)
@@ -352,101 +677,60 @@ func SpoolsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetFilterMetadata() (materials []string, brands []string) {
-	spools, err := spoolman.FindSpools()
+// spoolPage returns the page of the list selected by filters and how many
+// spools match in all. Spoolman filters, sorts and pages the list; filters
+// it cannot apply, or Spoolman being unreachable, fall back to doing that
+// locally on the cached spools. Searches without a sort are ranked by
+// relevance and color distance.
+func (h *Handler) spoolPage(ctx context.Context, filters SpoolFilters, snap spoolman.Snapshot) ([]spoolman.Spool, int) {
+	if q, ok := filters.spoolmanQuery(); ok && !snap.Stale {
+		page, err := h.spoolman.QuerySpools(ctx, q)
+		if err == nil {
+			return page.Spools, page.Total
+		}
+		log.Printf("Error querying spools, filtering locally: %+v", err)
+	}
+
+	filtered, _ := applyFilters(&snap.Spools, filters, h.layout)
+	// The cached spools are shared, so sort a copy
+	sorted := slices.Clone(*filtered)
+	if filters.Sort == "" {
+		rankSpools(sorted, filters)
+	} else {
+		sortSpools(sorted, filters.Sort)
+	}
+	start := min((filters.Page-1)*filters.PerPage, len(sorted))
+	end := min(start+filters.PerPage, len(sorted))
+	return sorted[start:end], len(sorted)
+}
+
+func (h *Handler) GetFilterMetadata(ctx context.Context) (materials []string, brands []string) {
+	snap, err := h.spools.Spools(ctx)
 	if err != nil {
 		log.Printf("Error finding spools: %+v", err)
 		return
 	}
-	materials = getUniqueMaterials(spools)
-	brands = getUniqueBrands(spools)
+	materials = getUniqueMaterials(&snap.Spools)
+	brands = getUniqueBrands(&snap.Spools)
 	return materials, brands
 }
 
 // FilterMetadataHandler returns available filter options
-func FilterMetadataHandler(w http.ResponseWriter, r *http.Request) {
-	spools, err := spoolman.FindSpools()
+func (h *Handler) FilterMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.spools.Spools(r.Context())
 	if err != nil {
 		log.Printf("Error finding spools: %+v", err)
 		http.Error(w, "Error finding spools", http.StatusInternalServerError)
 		return
 	}
 
-	materials := getUniqueMaterials(spools)
-	brands := getUniqueBrands(spools)
+	materials := getUniqueMaterials(&snap.Spools)
+	brands := getUniqueBrands(&snap.Spools)
 
-	component := templates.FilterOptions(materials, brands)
+	component := templates.FilterOptions(materials, brands, h.layout.Chambers())
 	err = component.Render(r.Context(), w)
 	if err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
 		return
 	}
 }
-
-// TransferLocationHandler proxies location transfer requests to Kafka HTTP bridge
-func TransferLocationHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Read the request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error reading request body: %v", err)
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Validate that it's valid JSON
-	var payload map[string]interface{}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Error parsing JSON: %v", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
-		return
-	}
-
-	// Log the transfer request
-	log.Printf("Location transfer request: %s", string(body))
-
-	// Forward to Kafka HTTP bridge
-	kafkaURL := "https://kafka.tryy3.dev/topics/3dprinter-filament-transfer-initiated"
-	req, err := http.NewRequest("POST", kafkaURL, bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("Error creating Kafka request: %v", err)
-		http.Error(w, "Error creating request", http.StatusInternalServerError)
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
-
-	// Execute the request
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error sending to Kafka: %v", err)
-		http.Error(w, "Error forwarding to Kafka: "+err.Error(), http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read Kafka response
-	kafkaBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading Kafka response: %v", err)
-		http.Error(w, "Error reading Kafka response", http.StatusInternalServerError)
-		return
-	}
-
-	// Forward Kafka response status and body
-	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
-	w.WriteHeader(resp.StatusCode)
-	w.Write(kafkaBody)
-
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		log.Printf("Location transfer successful")
-	} else {
-		log.Printf("Location transfer failed with status %d: %s", resp.StatusCode, string(kafkaBody))
-	}
-}
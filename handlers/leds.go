@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tryy3/filament-chamber/manager"
+	"github.com/tryy3/filament-chamber/simulator"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+type locateResponse struct {
+	SpoolId     int                    `json:"spool_id"`
+	Location    string                 `json:"location"`
+	LED         string                 `json:"led"`
+	ColorHex    string                 `json:"color_hex"`
+	Blink       bool                   `json:"blink"`
+	DurationMs  int64                  `json:"duration_ms"`
+	Controllers []manager.ServerResult `json:"controllers"`
+}
+
+// LocateSpoolHandler lights the LED of the slot a spool is stored in
+// (POST /api/spool/{id}/locate). Pass ?blink=true to blink instead of
+// lighting the slot steadily.
+func (h *Handler) LocateSpoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	spool, err := h.spoolman.GetSpool(r.Context(), id)
+	if errors.Is(err, spoolman.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting spool: %+v", err)
+		http.Error(w, "Error getting spool", http.StatusInternalServerError)
+		return
+	}
+	if spool == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	location := spoolman.GetSpoolLocation(*spool)
+	slot, ok := h.layout.ParseLocation(location)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Spool #%d is not in a chamber slot (location: %s)", id, location), http.StatusConflict)
+		return
+	}
+	led := slot.LED()
+	if led == "" || !h.leds.HasLED(led) {
+		http.Error(w, fmt.Sprintf("Slot %s has no LED", slot.Location()), http.StatusConflict)
+		return
+	}
+
+	colorHex := spoolman.GetFilamentColorHex(spool.Filament)
+	color, err := manager.ParseHexColor(colorHex)
+	if err != nil {
+		log.Printf("Spool %d has an unusable color %q, using white: %v", id, colorHex, err)
+		colorHex = "FFFFFF"
+		color = []int{255, 255, 255}
+	}
+
+	blink := h.cfg.LocateBlink
+	if v := r.URL.Query().Get("blink"); v != "" {
+		blink, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid blink value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results := h.leds.Locate(r.Context(), led, color, h.cfg.LocateDuration, blink)
+	log.Printf("Locating spool %d at %s (LED %s)", id, slot.Location(), led)
+
+	// Report a failure only if no controller accepted the frame
+	status := http.StatusOK
+	failed := 0
+	for _, result := range results {
+		if !result.OK() {
+			failed++
+		}
+	}
+	if failed > 0 && failed == len(results) {
+		status = http.StatusBadGateway
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(locateResponse{
+		SpoolId:     id,
+		Location:    slot.Location(),
+		LED:         led,
+		ColorHex:    colorHex,
+		Blink:       blink,
+		DurationMs:  h.cfg.LocateDuration.Milliseconds(),
+		Controllers: results,
+	}); err != nil {
+		log.Printf("Error encoding locate response: %v", err)
+	}
+}
+
+// showFilterOnLEDs mirrors a spool filter result on the chamber LEDs:
+// matching spools light in their filament color, other occupied slots are
+// dimmed and empty slots are off. Clearing the filters turns the LEDs off.
+func (h *Handler) showFilterOnLEDs(filters SpoolFilters, spoolsByLocation map[string]*spoolman.Spool, filteredIDs map[int]bool) {
+	if filters.isEmpty() {
+		h.leds.Clear(context.Background())
+		return
+	}
+
+	frame := map[string][]int{}
+	for _, chamber := range h.layout.Chambers() {
+		for _, row := range chamber.Grid() {
+			for _, slot := range row {
+				led := slot.LED()
+				spool, ok := spoolsByLocation[slot.Location()]
+				if led == "" || !ok {
+					continue
+				}
+				color, err := manager.ParseHexColor(spoolman.GetFilamentColorHex(spool.Filament))
+				if err != nil {
+					color = []int{255, 255, 255}
+				}
+				if !filteredIDs[spool.Id] {
+					color = manager.Dim(color, h.cfg.FilterDim)
+				}
+				frame[led] = color
+			}
+		}
+	}
+	for _, result := range h.leds.ShowFrame(context.Background(), frame, h.cfg.FilterIdleTimeout) {
+		if !result.OK() {
+			log.Printf("Error showing filter on LED controller %s: %v", result.Address, result.Error)
+		}
+	}
+}
+
+type simulatorResponse struct {
+	// LEDs maps slot LED names to the color last sent to them.
+	LEDs   map[string][]int  `json:"leds"`
+	Frames []simulator.Frame `json:"frames"`
+}
+
+// SimulatorStateHandler returns what the simulated controllers currently
+// show (GET /api/simulator/leds).
+func (h *Handler) SimulatorStateHandler(w http.ResponseWriter, r *http.Request) {
+	if h.sim == nil {
+		http.Error(w, "LED simulator is not enabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(simulatorResponse{
+		LEDs:   h.sim.Colors(h.layout.Strips()),
+		Frames: h.sim.Frames(),
+	}); err != nil {
+		log.Printf("Error encoding simulator state: %v", err)
+	}
+}
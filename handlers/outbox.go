@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tryy3/filament-chamber/outbox"
+	"github.com/tryy3/filament-chamber/templates"
+)
+
+// OutboxHandler renders the transfer outbox for the admin page
+// (GET /admin/outbox).
+func (h *Handler) OutboxHandler(w http.ResponseWriter, r *http.Request) {
+	component := templates.OutboxEntries(h.outbox.Entries())
+	if err := component.Render(r.Context(), w); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RetryOutboxHandler schedules a pending or failed transfer for immediate
+// delivery (POST /api/outbox/{id}/retry) and renders the updated outbox.
+func (h *Handler) RetryOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.outbox.Retry(id); err != nil {
+		h.outboxError(w, r, id, err)
+		return
+	}
+	log.Printf("Retrying outbox entry %s", id)
+	h.OutboxHandler(w, r)
+}
+
+// DiscardOutboxHandler deletes a transfer without delivering it
+// (POST /api/outbox/{id}/discard) and renders the updated outbox.
+func (h *Handler) DiscardOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.outbox.Discard(id); err != nil {
+		h.outboxError(w, r, id, err)
+		return
+	}
+	log.Printf("Discarded outbox entry %s", id)
+	h.transfers.Resolve(id, errors.New("discarded from the outbox"))
+	h.OutboxHandler(w, r)
+}
+
+func (h *Handler) outboxError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if errors.Is(err, outbox.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf("Error updating outbox entry %s: %v", id, err)
+	http.Error(w, err.Error(), http.StatusConflict)
+}
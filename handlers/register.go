@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+
+	"github.com/tryy3/filament-chamber/colors"
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// RegisterSpoolRequest is the body of POST /api/spools/register: the main
+// section of a scanned OpenPrintTag, as translated by opt_translator.js.
+type RegisterSpoolRequest struct {
+	Main json.RawMessage `json:"main"`
+}
+
+// OPTMain holds the fields of an OpenPrintTag main section a spool is
+// registered from. The tag has many more, which are ignored.
+type OPTMain struct {
+	BrandName        string   `json:"brand_name"`
+	MaterialType     string   `json:"material_type"`
+	MaterialName     string   `json:"material_name"`
+	PrimaryColor     string   `json:"primary_color"`
+	SecondaryColor0  string   `json:"secondary_color_0"`
+	SecondaryColor1  string   `json:"secondary_color_1"`
+	SecondaryColor2  string   `json:"secondary_color_2"`
+	SecondaryColor3  string   `json:"secondary_color_3"`
+	SecondaryColor4  string   `json:"secondary_color_4"`
+	Tags             []any    `json:"tags"`
+	Density          *float32 `json:"density"`
+	FilamentDiameter *float32 `json:"filament_diameter"`
+	// NominalWeight is the net weight the filament is sold as and
+	// ActualWeight what this spool held when full, in grams.
+	NominalWeight        *float32 `json:"nominal_netto_full_weight"`
+	ActualWeight         *float32 `json:"actual_netto_full_weight"`
+	EmptyContainerWeight *float32 `json:"empty_container_weight"`
+	MinPrintTemperature  *float32 `json:"min_print_temperature"`
+	MaxPrintTemperature  *float32 `json:"max_print_temperature"`
+	MinBedTemperature    *float32 `json:"min_bed_temperature"`
+	MaxBedTemperature    *float32 `json:"max_bed_temperature"`
+}
+
+// defaultDiameter is the filament diameter OpenPrintTag assumes when a tag
+// does not say, in mm.
+const defaultDiameter = 1.75
+
+// registerResponse is the spool added by a registration.
+type registerResponse struct {
+	Spool           spoolForOptResponse `json:"spool"`
+	VendorCreated   bool                `json:"vendor_created"`
+	FilamentCreated bool                `json:"filament_created"`
+}
+
+// NewSpool maps the tag onto a spool and its filament. Temperature ranges
+// become the middle of the range, and a missing diameter is 1.75 mm.
+func (m OPTMain) NewSpool() spoolman.NewSpool {
+	value := func(v *float32) float32 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	temperature := func(lo, hi *float32) int {
+		switch {
+		case lo != nil && hi != nil:
+			return int(math.Round(float64(*lo+*hi) / 2))
+		case lo != nil:
+			return int(math.Round(float64(*lo)))
+		case hi != nil:
+			return int(math.Round(float64(*hi)))
+		}
+		return 0
+	}
+
+	f := spoolman.FilamentSpec{
+		Vendor:       m.BrandName,
+		Name:         m.MaterialName,
+		Material:     m.MaterialType,
+		ColorHex:     m.PrimaryColor,
+		Density:      value(m.Density),
+		Diameter:     defaultDiameter,
+		Weight:       value(m.NominalWeight),
+		SpoolWeight:  value(m.EmptyContainerWeight),
+		ExtruderTemp: temperature(m.MinPrintTemperature, m.MaxPrintTemperature),
+		BedTemp:      temperature(m.MinBedTemperature, m.MaxBedTemperature),
+	}
+	if m.FilamentDiameter != nil {
+		f.Diameter = *m.FilamentDiameter
+	}
+	for _, c := range []string{m.SecondaryColor0, m.SecondaryColor1, m.SecondaryColor2, m.SecondaryColor3, m.SecondaryColor4} {
+		if c != "" {
+			f.MultiColorHexes = append(f.MultiColorHexes, c)
+		}
+	}
+	if len(f.MultiColorHexes) > 0 {
+		// Tags say how the colors are laid out; without one they are taken
+		// to change along the filament
+		f.MultiColorDirection = colors.Longitudinal
+		for _, tag := range m.Tags {
+			if tag == "coextruded" {
+				f.MultiColorDirection = colors.Coaxial
+			}
+		}
+	}
+
+	n := spoolman.NewSpool{Filament: f}
+	if actual := value(m.ActualWeight); actual != f.Weight {
+		n.InitialWeight = actual
+	}
+	return n
+}
+
+// RegisterSpoolHandler adds the spool of a scanned OpenPrintTag to Spoolman
+// (POST /api/spools/register), along with its vendor and filament unless
+// Spoolman already has them, and answers with the new spool.
+func (h *Handler) RegisterSpoolHandler(w http.ResponseWriter, r *http.Request) {
+	var req RegisterSpoolRequest
+	if !decodeStrict(w, r, &req) {
+		return
+	}
+	if len(req.Main) == 0 || string(req.Main) == "null" {
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{
+			Error:   "invalid_registration",
+			Message: "The spool registration is not valid",
+			Fields:  []fieldError{{Field: "main", Message: "is required"}},
+		})
+		return
+	}
+	var tag OPTMain
+	if err := json.Unmarshal(req.Main, &tag); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_json", Message: "Invalid OpenPrintTag main section: " + err.Error()})
+		return
+	}
+
+	reg, err := h.spoolman.RegisterSpool(r.Context(), tag.NewSpool())
+	if err != nil {
+		writeSpoolWriteError(w, 0, "registration", err)
+		return
+	}
+	log.Printf("Registered spool %d from a tag (new vendor: %t, new filament: %t)", reg.Spool.Id, reg.VendorCreated, reg.FilamentCreated)
+
+	h.spools.Invalidate()
+	h.live.Publish(hub.TypeSpool, hub.SpoolChange{ID: reg.Spool.Id, Change: "added"})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	out := registerResponse{
+		Spool:           newSpoolResponse(reg.Spool),
+		VendorCreated:   reg.VendorCreated,
+		FilamentCreated: reg.FilamentCreated,
+	}
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("Error encoding registered spool: %v", err)
+	}
+}
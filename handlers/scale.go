@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/scale"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// ScaleScanRequest is the body of POST /api/scale/scan.
+type ScaleScanRequest struct {
+	SpoolId spoolman.SpoolID `json:"spool_id"`
+}
+
+// ScaleWeighing is the data of a weighing event: the outcome of weighing a
+// scanned spool on a scale.
+type ScaleWeighing struct {
+	Scale    string            `json:"scale"`
+	SpoolID  int               `json:"spool_id"`
+	Weighing spoolman.Weighing `json:"weighing"`
+	Recorded bool              `json:"recorded"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// scaleStateResponse is the body of GET /api/scale.
+type scaleStateResponse struct {
+	Scales  []scale.State `json:"scales"`
+	Pending *scale.Scan   `json:"pending"`
+}
+
+// ScaleReadingHandler accepts a raw scale reading (POST
+// /api/scale/readings), e.g. {"scale": "bench", "grams": 812.4} from an ESP
+// load cell, and answers with the filtered state of the scale.
+func (h *Handler) ScaleReadingHandler(w http.ResponseWriter, r *http.Request) {
+	var reading scale.Reading
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&reading); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_json", Message: "Invalid scale reading: " + err.Error()})
+		return
+	}
+
+	var fields []fieldError
+	reading.Scale = strings.TrimSpace(reading.Scale)
+	if reading.Scale == "" {
+		fields = append(fields, fieldError{Field: "scale", Message: "is required"})
+	}
+	if math.IsNaN(reading.Grams) || math.IsInf(reading.Grams, 0) {
+		fields = append(fields, fieldError{Field: "grams", Message: "must be a number"})
+	}
+	if len(fields) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{Error: "invalid_reading", Message: "The scale reading is not valid", Fields: fields})
+		return
+	}
+
+	state := h.scales.Add(reading)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		log.Printf("Error encoding scale state: %v", err)
+	}
+}
+
+// ScaleScanHandler marks a scanned spool as the next one to weigh (POST
+// /api/scale/scan). Its weight is recorded once it settles on a scale.
+func (h *Handler) ScaleScanHandler(w http.ResponseWriter, r *http.Request) {
+	var req ScaleScanRequest
+	if !decodeStrict(w, r, &req) {
+		return
+	}
+	if req.SpoolId <= 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{
+			Error:   "invalid_scan",
+			Message: "The scan is not valid",
+			Fields:  []fieldError{{Field: "spool_id", Message: "must be a spool ID"}},
+		})
+		return
+	}
+
+	h.scales.Scanned(int(req.SpoolId))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ScaleStateHandler returns the weight on every scale and the spool waiting
+// to be weighed (GET /api/scale).
+func (h *Handler) ScaleStateHandler(w http.ResponseWriter, r *http.Request) {
+	out := scaleStateResponse{Scales: h.scales.States()}
+	if scan, ok := h.scales.Pending(); ok {
+		out.Pending = &scan
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("Error encoding scale state: %v", err)
+	}
+}
+
+// RecordWeighing records the stable weight of a scanned spool in Spoolman.
+// Weights that look wrong, e.g. less than the empty spool, are not recorded
+// since nobody confirmed them; the weigh-in page can still record them.
+func (h *Handler) RecordWeighing(ctx context.Context, sw scale.Weighing) {
+	out := ScaleWeighing{Scale: sw.Scale, SpoolID: sw.SpoolID}
+	defer func() {
+		h.live.Publish(hub.TypeWeighing, out)
+	}()
+
+	spool, err := h.spoolman.GetSpool(ctx, sw.SpoolID)
+	if err == nil && spool == nil {
+		err = spoolman.ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Error getting spool %d to weigh: %+v", sw.SpoolID, err)
+		out.Error = "Could not get the spool from Spoolman: " + err.Error()
+		return
+	}
+
+	gross := float32(sw.Grams)
+	out.Weighing = spoolman.Weigh(*spool, gross)
+	if len(out.Weighing.Warnings) > 0 {
+		log.Printf("Not recording %.0fg for spool %d: %v", gross, sw.SpoolID, out.Weighing.Warnings)
+		out.Error = "Not recorded: " + strings.Join(out.Weighing.Warnings, "; ")
+		return
+	}
+
+	spool, err = h.spoolman.MeasureSpool(ctx, sw.SpoolID, gross)
+	if err != nil {
+		log.Printf("Error recording %.0fg for spool %d: %+v", gross, sw.SpoolID, err)
+		if errors.Is(err, spoolman.ErrRejected) {
+			out.Error = err.Error()
+		} else {
+			out.Error = "Could not record the weight in Spoolman: " + err.Error()
+		}
+		return
+	}
+	h.spoolChanged(spool)
+	out.Weighing.After = spoolman.GetSpoolRemainingWeight(*spool)
+	out.Recorded = true
+	log.Printf("Spool %d weighed %.0fg on %s: %.0fg → %.0fg remaining", sw.SpoolID, gross, sw.Scale, out.Weighing.Before, out.Weighing.After)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tryy3/filament-chamber/colors"
+	"github.com/tryy3/filament-chamber/search"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// Weights of the spool fields in free-text search: a match in the name
+// counts most, one in a comment or extra field least. Color is the color
+// name, e.g. "Dark Grey".
+const (
+	weightName     = 5
+	weightBrand    = 4
+	weightMaterial = 4
+	weightColor    = 3
+	weightID       = 3
+	weightLot      = 2
+	weightLocation = 2
+	weightComment  = 1
+	weightExtra    = 1
+)
+
+// spoolSearchFields lists the text a free-text search looks at.
+func spoolSearchFields(spool spoolman.Spool) []search.Field {
+	fields := []search.Field{
+		{Text: spoolman.GetFilamentName(spool.Filament), Weight: weightName},
+		{Text: spoolman.GetFilamentBrand(spool.Filament), Weight: weightBrand},
+		{Text: spoolman.GetFilamentMaterial(spool.Filament), Weight: weightMaterial},
+		{Text: fmt.Sprintf("#%d", spool.Id), Weight: weightID},
+		// Both spellings of grey, so "dark gray" finds "Dark Grey"
+		{Text: colorSearchText(spoolman.GetFilamentColorName(spool.Filament)), Weight: weightColor},
+		{Text: spoolman.GetSpoolLotNr(spool), Weight: weightLot},
+		{Text: spoolman.GetSpoolComment(spool), Weight: weightComment},
+		{Text: spoolman.GetFilamentComment(spool.Filament), Weight: weightComment},
+	}
+	if spool.Location != nil {
+		fields = append(fields, search.Field{Text: spoolman.GetSpoolLocation(spool), Weight: weightLocation})
+	}
+	for _, extra := range []map[string]string{spool.Extra, spool.Filament.Extra} {
+		for _, v := range extra {
+			fields = append(fields, search.Field{Text: extraText(v), Weight: weightExtra})
+		}
+	}
+	return fields
+}
+
+// colorSearchText adds the American spelling to color names with grey.
+func colorSearchText(name string) string {
+	if strings.Contains(name, "Grey") {
+		return name + " " + strings.ReplaceAll(name, "Grey", "Gray")
+	}
+	return name
+}
+
+// extraText returns the text of an extra field value. Spoolman stores them
+// JSON encoded, so text fields are quoted.
+func extraText(v string) string {
+	var s string
+	if err := json.Unmarshal([]byte(v), &s); err == nil {
+		return s
+	}
+	return v
+}
+
+// spoolColors returns the colors of a spool's filament: its color and, for
+// multi-color filaments, every color of it.
+func spoolColors(spool spoolman.Spool) []colors.RGB {
+	var out []colors.RGB
+	if spool.Filament.ColorHex != nil {
+		if c, err := colors.ParseHex(spoolman.GetFilamentColorHex(spool.Filament)); err == nil {
+			out = append(out, c)
+		}
+	}
+	return append(out, colors.ParseHexList(spoolman.GetFilamentMultiColorHexes(spool.Filament))...)
+}
+
+// colorDistance is the ΔE2000 distance from target to the closest color of
+// the spool, and false if the spool has no color.
+func colorDistance(spool spoolman.Spool, target colors.Lab) (float64, bool) {
+	return colors.Closest(target, spoolColors(spool))
+}
+
+// rankSpools orders spools for a search without an explicit sort: best
+// text match first, then closest color, then by ID.
+func rankSpools(spools []spoolman.Spool, filters SpoolFilters) {
+	type rank struct {
+		score, distance float64
+	}
+	ranks := make(map[int]rank, len(spools))
+	for _, spool := range spools {
+		var r rank
+		if !filters.terms.Empty() {
+			r.score = filters.terms.Score(spoolSearchFields(spool))
+		}
+		if filters.color != nil {
+			r.distance, _ = colorDistance(spool, *filters.color)
+		}
+		ranks[spool.Id] = r
+	}
+	slices.SortStableFunc(spools, func(a, b spoolman.Spool) int {
+		ra, rb := ranks[a.Id], ranks[b.Id]
+		return cmp.Or(
+			cmp.Compare(rb.score, ra.score),
+			cmp.Compare(ra.distance, rb.distance),
+			cmp.Compare(a.Id, b.Id),
+		)
+	})
+}
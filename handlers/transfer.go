@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tryy3/filament-chamber/events"
+	"github.com/tryy3/filament-chamber/outbox"
+	"github.com/tryy3/filament-chamber/spoolman"
+	"github.com/tryy3/filament-chamber/transfers"
+)
+
+// Bounds for the transfer timestamp. Transfers are sent right after the
+// location tag is scanned, so anything far off means a wrong clock or a
+// replayed request.
+const (
+	maxTransferAge  = 24 * time.Hour
+	maxTransferSkew = 5 * time.Minute
+)
+
+// TransferRequest is the location transfer sent by the browser, shaped as a
+// Kafka REST Proxy produce request.
+type TransferRequest struct {
+	Records []TransferRecord `json:"records"`
+}
+
+type TransferRecord struct {
+	Value TransferEvent `json:"value"`
+}
+
+// TransferEvent moves one spool to a location.
+type TransferEvent struct {
+	SpoolId    spoolman.SpoolID `json:"spoolId"`
+	LocationId string           `json:"locationId"`
+	Timestamp  time.Time        `json:"timestamp"`
+	// EventId identifies the event for idempotent delivery. It is generated
+	// when the browser does not send one.
+	EventId string `json:"eventId,omitempty"`
+	// TagData is the translated OPT payload of the scanned spool tag, if any.
+	TagData json.RawMessage `json:"tagData,omitempty"`
+}
+
+// apiError is the JSON body of a rejected API request.
+type apiError struct {
+	// Error is a stable, machine readable code.
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Fields  []fieldError `json:"fields,omitempty"`
+	// EventId refers to the stored transfer, if one was created.
+	EventId string `json:"event_id,omitempty"`
+	// Warnings explain why a request needs to be confirmed.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// fieldError explains why one field of a request was rejected.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, e apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		log.Printf("Error encoding API error: %v", err)
+	}
+}
+
+// TransferLocationHandler applies a location transfer scanned in the
+// browser, either by producing it to Kafka or by updating the
+// spool in Spoolman directly, depending on the configured transfer mode.
+// Transfers that cannot be delivered right away are kept in the outbox and
+// answered with 202 Accepted. Either way the browser can wait for the
+// outcome with TransferStatusHandler.
+func (h *Handler) TransferLocationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read the request body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_request", Message: "Error reading request body"})
+		return
+	}
+	defer r.Body.Close()
+
+	// Log the transfer request
+	log.Printf("Location transfer request: %s", string(body))
+
+	var req TransferRequest
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		log.Printf("Error parsing transfer request: %v", err)
+		writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_json", Message: "Invalid transfer request: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.TransferTimeout)
+	defer cancel()
+
+	fields := h.validateTransfer(ctx, &req, time.Now())
+	if len(fields) > 0 {
+		log.Printf("Rejected transfer request: %+v", fields)
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{Error: "invalid_transfer", Message: "The transfer request is not valid", Fields: fields})
+		return
+	}
+
+	// Store the transfer before acknowledging it, so it survives Kafka or
+	// Spoolman being down. The event ID is the idempotency key: a browser
+	// resending the same event gets the stored outcome back.
+	event := &req.Records[0].Value
+	if event.EventId == "" {
+		event.EventId = outbox.NewID()
+	}
+	summary := fmt.Sprintf("Spool #%d to %s", event.SpoolId, event.LocationId)
+	entry, existed, err := h.outbox.Enqueue(event.EventId, summary, req)
+	if err != nil {
+		log.Printf("Error storing transfer in outbox: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, apiError{Error: "outbox_unavailable", Message: "Could not store the transfer"})
+		return
+	}
+	if existed {
+		log.Printf("Transfer %s was already received (%s)", entry.ID, entry.Status)
+	}
+	h.transfers.Track(entry.ID, int(event.SpoolId), event.LocationId)
+
+	entry, err = h.outbox.Deliver(ctx, entry.ID, h.DeliverTransfer)
+	if err == nil && entry.Status == outbox.StatusFailed {
+		// A resent transfer that failed before. It stays failed until it
+		// is retried from the admin page.
+		err = errors.New(entry.LastError)
+		h.transfers.Resolve(entry.ID, err)
+	}
+	switch {
+	case err == nil:
+		log.Printf("Location transfer successful: %s", entry.Summary)
+		if h.cfg.TransferMode == TransferModeSpoolman {
+			// Also covers a resent transfer that was delivered before.
+			h.transfers.Resolve(entry.ID, nil)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry.Result)
+	case outbox.IsPermanent(err):
+		log.Printf("Location transfer rejected: %s: %v", entry.Summary, err)
+		writeAPIError(w, http.StatusBadGateway, apiError{Error: "transfer_rejected", Message: err.Error(), EventId: entry.ID})
+	case entry.Status == outbox.StatusFailed:
+		log.Printf("Location transfer gave up: %s: %v", entry.Summary, err)
+		writeAPIError(w, http.StatusBadGateway, apiError{Error: "transfer_failed", Message: err.Error(), EventId: entry.ID})
+	default:
+		log.Printf("Location transfer queued for retry: %s: %v", entry.Summary, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(transferQueuedResponse{
+			Status:      string(entry.Status),
+			EventId:     entry.ID,
+			Message:     "The transfer is stored and will be retried",
+			NextAttempt: entry.NextAttempt,
+		}); err != nil {
+			log.Printf("Error encoding transfer response: %v", err)
+		}
+	}
+}
+
+type transferQueuedResponse struct {
+	Status      string    `json:"status"`
+	EventId     string    `json:"event_id"`
+	Message     string    `json:"message"`
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+}
+
+// validateTransfer checks a transfer request and returns the rejected
+// fields. The spool and location are also looked up in Spoolman, but only
+// to reject what Spoolman says does not exist: while Spoolman is
+// unreachable the transfer goes into the outbox unchecked, and a missing
+// spool fails on delivery instead.
+func (h *Handler) validateTransfer(ctx context.Context, req *TransferRequest, now time.Time) []fieldError {
+	var fields []fieldError
+	fail := func(field, format string, args ...any) {
+		fields = append(fields, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(req.Records) != 1 {
+		fail("records", "must contain exactly one transfer, got %d", len(req.Records))
+		return fields
+	}
+	event := &req.Records[0].Value
+	const prefix = "records[0].value."
+
+	if event.SpoolId <= 0 {
+		fail(prefix+"spoolId", "is required")
+	} else if _, err := h.spoolman.GetSpool(ctx, int(event.SpoolId)); errors.Is(err, spoolman.ErrNotFound) {
+		fail(prefix+"spoolId", "spool #%d does not exist", event.SpoolId)
+	} else if err != nil {
+		log.Printf("Could not check spool %d, checking it on delivery: %v", event.SpoolId, err)
+	}
+
+	event.LocationId = strings.TrimSpace(event.LocationId)
+	if event.LocationId == "" {
+		fail(prefix+"locationId", "is required")
+	} else if known, err := h.isKnownLocation(ctx, event.LocationId); err != nil {
+		log.Printf("Could not check location %q, accepting it: %v", event.LocationId, err)
+	} else if !known {
+		fail(prefix+"locationId", "%q is neither a chamber slot nor a Spoolman location", event.LocationId)
+	}
+
+	switch {
+	case event.Timestamp.IsZero():
+		fail(prefix+"timestamp", "is required")
+	case event.Timestamp.After(now.Add(maxTransferSkew)):
+		fail(prefix+"timestamp", "%s is in the future", event.Timestamp.Format(time.RFC3339))
+	case event.Timestamp.Before(now.Add(-maxTransferAge)):
+		fail(prefix+"timestamp", "%s is older than %s", event.Timestamp.Format(time.RFC3339), maxTransferAge)
+	}
+
+	if event.EventId != "" && !outbox.ValidID(event.EventId) {
+		fail(prefix+"eventId", "must be 1-64 letters, digits, dashes or underscores")
+	}
+
+	if tagData := bytes.TrimSpace(event.TagData); len(tagData) > 0 && tagData[0] != '{' && string(tagData) != "null" {
+		fail(prefix+"tagData", "must be an object")
+	}
+
+	return fields
+}
+
+// isKnownLocation reports whether location is a slot of a configured chamber
+// or a location Spoolman already uses.
+func (h *Handler) isKnownLocation(ctx context.Context, location string) (bool, error) {
+	if _, ok := h.layout.ParseLocation(location); ok {
+		return true, nil
+	}
+	locations, err := h.spoolman.FindLocations(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, known := range locations {
+		if strings.EqualFold(known, location) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeliverTransfer sends a stored transfer to Kafka or Spoolman, depending on
+// the transfer mode. It is the outbox delivery function for transfers.
+// Spoolman updates complete the tracked transfer right away, Kafka transfers
+// complete when their outcome is reported and time out from delivery.
+func (h *Handler) DeliverTransfer(ctx context.Context, e outbox.Entry) (json.RawMessage, error) {
+	var req TransferRequest
+	if err := json.Unmarshal(e.Payload, &req); err != nil || len(req.Records) != 1 {
+		return nil, outbox.Permanent(fmt.Errorf("unreadable transfer payload: %v", err))
+	}
+	event := req.Records[0].Value
+	// Transfers still in the outbox after a restart are tracked again.
+	h.transfers.Track(e.ID, int(event.SpoolId), event.LocationId)
+
+	var result json.RawMessage
+	var err error
+	switch h.cfg.TransferMode {
+	case TransferModeSpoolman:
+		result, err = h.sendToSpoolman(ctx, event)
+		if err == nil {
+			h.transfers.Resolve(e.ID, nil)
+		}
+	default:
+		// Transfers queued while Spoolman was down were not checked
+		_, err = h.spoolman.GetSpool(ctx, int(event.SpoolId))
+		switch {
+		case errors.Is(err, spoolman.ErrNotFound):
+			err = outbox.Permanent(fmt.Errorf("spool #%d does not exist", event.SpoolId))
+		case err != nil:
+			log.Printf("Could not check spool %d, sending transfer %s anyway: %v", event.SpoolId, e.ID, err)
+			fallthrough
+		default:
+			result, err = h.sendToKafka(ctx, req)
+		}
+	}
+	switch {
+	case err == nil:
+		h.transfers.Sent(e.ID)
+	case h.outbox.Final(e, err):
+		h.transfers.Resolve(e.ID, err)
+	}
+	return result, err
+}
+
+// maxTransferWait bounds how long TransferStatusHandler holds a request.
+const maxTransferWait = time.Minute
+
+// TransferStatusHandler returns the tracked state of a transfer
+// (GET /api/transfers/{id}). With ?wait=<duration> it waits up to that long
+// for the outcome, so the browser that initiated the transfer learns whether
+// the spool moved.
+func (h *Handler) TransferStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+	if v := r.URL.Query().Get("wait"); v != "" {
+		wait, err := time.ParseDuration(v)
+		if err != nil || wait < 0 {
+			writeAPIError(w, http.StatusBadRequest, apiError{Error: "invalid_request", Message: "wait must be a duration, e.g. 30s"})
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, min(wait, maxTransferWait))
+		defer cancel()
+	}
+
+	transfer, err := h.transfers.Wait(ctx, id)
+	if errors.Is(err, transfers.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, apiError{Error: "unknown_transfer", Message: "No transfer with this event ID is being tracked", EventId: id})
+		return
+	}
+	if err != nil {
+		log.Printf("Error waiting for transfer %s: %v", id, err)
+		writeAPIError(w, http.StatusInternalServerError, apiError{Error: "internal", Message: "Could not look up the transfer"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(transfer); err != nil {
+		log.Printf("Error encoding transfer: %v", err)
+	}
+}
+
+// sendToKafka produces the transfer to the transfer topic, keyed by spool ID
+// so all transfers of a spool stay in order on one partition. Rejected
+// records are permanent failures, anything else is retried.
+func (h *Handler) sendToKafka(ctx context.Context, transfer TransferRequest) (json.RawMessage, error) {
+	event := transfer.Records[0].Value
+	offset, err := h.transferEvents.Send(ctx, events.Record{
+		Key:   strconv.Itoa(int(event.SpoolId)),
+		Value: event,
+	})
+	if err != nil {
+		if !events.IsTemporary(err) {
+			return nil, outbox.Permanent(err)
+		}
+		return nil, fmt.Errorf("producing to Kafka: %w", err)
+	}
+	return json.Marshal(offset)
+}
+
+// sendToSpoolman sets the spool location in Spoolman and returns the updated
+// spool.
+func (h *Handler) sendToSpoolman(ctx context.Context, event TransferEvent) (json.RawMessage, error) {
+	spoolID := int(event.SpoolId)
+	spool, err := h.spoolman.UpdateSpoolLocation(ctx, spoolID, event.LocationId)
+	if errors.Is(err, spoolman.ErrNotFound) {
+		return nil, outbox.Permanent(fmt.Errorf("spool #%d does not exist", spoolID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("updating Spoolman: %w", err)
+	}
+	h.spools.Invalidate()
+	return json.Marshal(spool)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tryy3/filament-chamber/spoolman"
+	"github.com/tryy3/filament-chamber/templates"
+)
+
+// WeighRequest is the body of POST /api/spool/{id}/weigh.
+type WeighRequest struct {
+	// Weight is the gross weight of the spool on the scale, in grams.
+	Weight float32 `json:"weight"`
+	// DryRun only works out the outcome, without recording it.
+	DryRun bool `json:"dry_run"`
+	// Confirm records a weight that comes with warnings. Send it only after
+	// the warnings were shown.
+	Confirm bool `json:"confirm"`
+}
+
+// weighResponse is the outcome of a weigh-in.
+type weighResponse struct {
+	Spool    spoolForOptResponse `json:"spool"`
+	Weighing spoolman.Weighing   `json:"weighing"`
+	Recorded bool                `json:"recorded"`
+}
+
+// WeighPageHandler renders the weigh-in page of a spool (GET
+// /spool/{id}/weigh).
+func (h *Handler) WeighPageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	spool, err := h.spoolman.GetSpool(r.Context(), id)
+	if errors.Is(err, spoolman.ErrNotFound) || (err == nil && spool == nil) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting spool: %+v", err)
+		http.Error(w, "Error getting spool", http.StatusInternalServerError)
+		return
+	}
+
+	component := templates.SpoolWeigh(spool)
+	if err := component.Render(r.Context(), w); err != nil {
+		log.Printf("Error rendering template: %+v", err)
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// WeighSpoolHandler records the gross weight of a spool in Spoolman (POST
+// /api/spool/{id}/weigh) and answers with the remaining weight before and
+// after. Implausible weights are refused with their warnings (422) unless
+// the request confirms them; use dry_run to check them first.
+func (h *Handler) WeighSpoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := spoolPathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req WeighRequest
+	if !decodeStrict(w, r, &req) {
+		return
+	}
+	if err := spoolman.ValidateGross(req.Weight); err != nil {
+		writeSpoolWriteError(w, id, "weighing", err)
+		return
+	}
+
+	spool, err := h.spoolman.GetSpool(r.Context(), id)
+	if err == nil && spool == nil {
+		err = spoolman.ErrNotFound
+	}
+	if err != nil {
+		writeSpoolWriteError(w, id, "weighing", err)
+		return
+	}
+
+	out := weighResponse{Weighing: spoolman.Weigh(*spool, req.Weight)}
+	if !req.DryRun && !req.Confirm && len(out.Weighing.Warnings) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, apiError{
+			Error:    "unconfirmed_weighing",
+			Message:  "Not recorded: " + strings.Join(out.Weighing.Warnings, "; "),
+			Warnings: out.Weighing.Warnings,
+		})
+		return
+	}
+	if !req.DryRun {
+		spool, err = h.spoolman.MeasureSpool(r.Context(), id, req.Weight)
+		if err != nil {
+			writeSpoolWriteError(w, id, "weighing", err)
+			return
+		}
+		h.spoolChanged(spool)
+		// Spoolman has the final say on the remaining weight
+		out.Weighing.After = spoolman.GetSpoolRemainingWeight(*spool)
+		out.Recorded = true
+		if len(out.Weighing.Warnings) > 0 {
+			log.Printf("Recorded %.0fg for spool %d, confirmed despite warnings: %v", req.Weight, id, out.Weighing.Warnings)
+		}
+	}
+	out.Spool = newSpoolResponse(spool)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("Error encoding weighing: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package hub fans out live updates (spool changes, transfers, LED state,
+// sensor and scale readings) to every connected browser.
+package hub
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Event types sent to subscribers.
+const (
+	TypeSpool    = "spool"
+	TypeFilament = "filament"
+	TypeVendor   = "vendor"
+	TypeTransfer = "transfer"
+	TypeLEDs     = "leds"
+	TypeSensor   = "sensor"
+	TypeScale    = "scale"
+	TypeWeighing = "weighing"
+)
+
+// SpoolChange is the data of a TypeSpool event. An ID of 0 means any spool
+// may have changed.
+type SpoolChange struct {
+	ID       int    `json:"id"`
+	Location string `json:"location,omitempty"`
+	// Change is "added", "updated" or "deleted" for changes made in Spoolman.
+	Change string `json:"change,omitempty"`
+}
+
+// EntityChange is the data of TypeFilament and TypeVendor events.
+type EntityChange struct {
+	ID     int    `json:"id"`
+	Change string `json:"change"`
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before events are dropped for it.
+const subscriberBuffer = 64
+
+// Event is one update. Data is sent as JSON.
+type Event struct {
+	ID   uint64
+	Type string
+	Data any
+}
+
+// Hub delivers published events to all current subscribers. Events
+// published with Retain are also replayed to subscribers that join later,
+// latest per key, so they start from the current state.
+type Hub struct {
+	mu       sync.Mutex
+	seq      uint64
+	subs     map[chan Event]struct{}
+	retained map[string]Event
+	// order keeps retained events in first-published order for replay.
+	order []string
+}
+
+func New() *Hub {
+	return &Hub{
+		subs:     map[chan Event]struct{}{},
+		retained: map[string]Event{},
+	}
+}
+
+// Publish sends an event to every subscriber. Subscribers that are too far
+// behind miss it.
+func (h *Hub) Publish(typ string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publish(typ, data)
+}
+
+// Retain publishes an event and keeps it, replacing the previous event with
+// the same type and key, for subscribers that join later.
+func (h *Hub) Retain(typ, key string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := h.publish(typ, data)
+	k := typ + "/" + key
+	if _, ok := h.retained[k]; !ok {
+		h.order = append(h.order, k)
+	}
+	h.retained[k] = e
+}
+
+// publish numbers and fans out an event. h.mu must be held.
+func (h *Hub) publish(typ string, data any) Event {
+	h.seq++
+	e := Event{ID: h.seq, Type: typ, Data: data}
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("dropping live event for slow subscriber", "type", typ, "id", e.ID)
+		}
+	}
+	return e
+}
+
+// Subscribe returns a channel receiving the retained events followed by
+// every new event, and a function that ends the subscription.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Event, max(subscriberBuffer, len(h.order)+subscriberBuffer))
+	for _, k := range h.order {
+		ch <- h.retained[k]
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs, ch)
+	}
+}
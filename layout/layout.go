@@ -0,0 +1,288 @@
+// Package layout describes the physical chambers: their slot grids, the
+// Spoolman location strings that refer to each slot and which LED lights
+// each slot.
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultSlotFormat names slots by row followed by column, e.g. "A1".
+const DefaultSlotFormat = "{row}{column}"
+
+// ChamberConfig is the declarative description of one chamber.
+type ChamberConfig struct {
+	// ID identifies the chamber, e.g. "chamber1".
+	ID string `yaml:"id"`
+	// Name is shown in the UI. Defaults to ID.
+	Name string `yaml:"name"`
+	// LocationPrefix is prepended to slot names to form the Spoolman
+	// location string. Defaults to ID + "_", giving "chamber1_A1".
+	LocationPrefix string `yaml:"location_prefix"`
+	// Default marks the chamber that bare slot names ("A1") refer to.
+	// The first chamber is used when none is marked.
+	Default bool     `yaml:"default"`
+	Rows    []string `yaml:"rows"`
+	Columns []string `yaml:"columns"`
+	// SlotFormat builds slot names from {row} and {column}.
+	SlotFormat string `yaml:"slot_format"`
+	// Disabled lists slot names that exist in the grid but cannot hold a spool.
+	Disabled []string `yaml:"disabled"`
+	// LEDs maps slots to LED strips, in strip order.
+	LEDs []StripConfig `yaml:"leds"`
+}
+
+// StripConfig describes one LED strip lighting a set of slots.
+type StripConfig struct {
+	// Controller is the name of the LED controller the strip is wired to.
+	Controller string `yaml:"controller"`
+	Pin        int    `yaml:"pin"`
+	// Slots lists the slot names in strip order.
+	Slots []string `yaml:"slots"`
+	// EmptyBefore places the unused LED before each slot LED instead of after.
+	EmptyBefore bool `yaml:"empty_before"`
+}
+
+// Layout is the validated set of chambers.
+type Layout struct {
+	chambers []*Chamber
+	byID     map[string]*Chamber
+	def      *Chamber
+}
+
+// Chamber is a grid of slots.
+type Chamber struct {
+	ID             string
+	Name           string
+	LocationPrefix string
+	Rows           []string
+	Columns        []string
+	Strips         []Strip
+
+	grid  [][]*Slot
+	slots map[string]*Slot
+}
+
+// Slot is one position in a chamber grid.
+type Slot struct {
+	Chamber  *Chamber
+	Name     string
+	Row      string
+	Column   string
+	Disabled bool
+	// HasLED reports whether a strip lights this slot.
+	HasLED bool
+}
+
+// Strip is an LED strip with its LEDs named by slot location.
+type Strip struct {
+	Controller  string
+	Pin         int
+	LEDs        []string
+	EmptyBefore bool
+}
+
+// New validates the chamber descriptions and builds a Layout.
+func New(configs []ChamberConfig) (*Layout, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("at least one chamber is required")
+	}
+
+	l := &Layout{
+		byID: map[string]*Chamber{},
+	}
+	var errs []error
+	prefixes := map[string]string{}
+	for i, cfg := range configs {
+		field := fmt.Sprintf("chambers[%d]", i)
+		c, err := newChamber(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+			continue
+		}
+		if _, ok := l.byID[c.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: chamber id %q is used more than once", field, c.ID))
+			continue
+		}
+		if other, ok := prefixes[c.LocationPrefix]; ok {
+			errs = append(errs, fmt.Errorf("%s: location prefix %q is already used by chamber %q", field, c.LocationPrefix, other))
+			continue
+		}
+		prefixes[c.LocationPrefix] = c.ID
+		if cfg.Default {
+			if l.def != nil {
+				errs = append(errs, fmt.Errorf("%s: only one chamber can be the default, %q already is", field, l.def.ID))
+				continue
+			}
+			l.def = c
+		}
+		l.chambers = append(l.chambers, c)
+		l.byID[c.ID] = c
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if l.def == nil {
+		l.def = l.chambers[0]
+	}
+	return l, nil
+}
+
+func newChamber(cfg ChamberConfig) (*Chamber, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	if len(cfg.Rows) == 0 {
+		return nil, errors.New("at least one row is required")
+	}
+	if len(cfg.Columns) == 0 {
+		return nil, errors.New("at least one column is required")
+	}
+
+	c := &Chamber{
+		ID:             cfg.ID,
+		Name:           cfg.Name,
+		LocationPrefix: cfg.LocationPrefix,
+		Rows:           cfg.Rows,
+		Columns:        cfg.Columns,
+		slots:          map[string]*Slot{},
+	}
+	if c.Name == "" {
+		c.Name = c.ID
+	}
+	if c.LocationPrefix == "" {
+		c.LocationPrefix = c.ID + "_"
+	}
+	format := cfg.SlotFormat
+	if format == "" {
+		format = DefaultSlotFormat
+	}
+
+	for _, row := range cfg.Rows {
+		gridRow := []*Slot{}
+		for _, column := range cfg.Columns {
+			name := strings.NewReplacer("{row}", row, "{column}", column).Replace(format)
+			if _, ok := c.slots[name]; ok {
+				return nil, fmt.Errorf("slot name %q is generated more than once, check slot_format", name)
+			}
+			slot := &Slot{
+				Chamber: c,
+				Name:    name,
+				Row:     row,
+				Column:  column,
+			}
+			c.slots[name] = slot
+			gridRow = append(gridRow, slot)
+		}
+		c.grid = append(c.grid, gridRow)
+	}
+
+	for _, name := range cfg.Disabled {
+		slot, ok := c.slots[name]
+		if !ok {
+			return nil, fmt.Errorf("disabled slot %q is not in the grid", name)
+		}
+		slot.Disabled = true
+	}
+
+	for i, strip := range cfg.LEDs {
+		if strip.Controller == "" {
+			return nil, fmt.Errorf("leds[%d]: controller is required", i)
+		}
+		s := Strip{
+			Controller:  strip.Controller,
+			Pin:         strip.Pin,
+			EmptyBefore: strip.EmptyBefore,
+		}
+		for _, name := range strip.Slots {
+			slot, ok := c.slots[name]
+			if !ok {
+				return nil, fmt.Errorf("leds[%d]: slot %q is not in the grid", i, name)
+			}
+			if slot.HasLED {
+				return nil, fmt.Errorf("leds[%d]: slot %q is already on another strip", i, name)
+			}
+			slot.HasLED = true
+			s.LEDs = append(s.LEDs, slot.Location())
+		}
+		c.Strips = append(c.Strips, s)
+	}
+
+	return c, nil
+}
+
+// Chambers returns the chambers in configuration order.
+func (l *Layout) Chambers() []*Chamber {
+	return l.chambers
+}
+
+// Chamber looks up a chamber by ID.
+func (l *Layout) Chamber(id string) (*Chamber, bool) {
+	c, ok := l.byID[id]
+	return c, ok
+}
+
+// Default returns the chamber that bare slot names refer to.
+func (l *Layout) Default() *Chamber {
+	return l.def
+}
+
+// ParseLocation resolves a Spoolman location string to a slot. Both the
+// prefixed form ("chamber1_A1") and bare slot names of the default chamber
+// ("A1") are accepted.
+func (l *Layout) ParseLocation(location string) (*Slot, bool) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, false
+	}
+	for _, c := range l.chambers {
+		if rest, ok := strings.CutPrefix(location, c.LocationPrefix); ok {
+			if slot, ok := c.Slot(rest); ok {
+				return slot, true
+			}
+		}
+	}
+	return l.def.Slot(location)
+}
+
+// Strips returns the LED strips of every chamber.
+func (l *Layout) Strips() []Strip {
+	strips := []Strip{}
+	for _, c := range l.chambers {
+		strips = append(strips, c.Strips...)
+	}
+	return strips
+}
+
+// Grid returns the slots row by row.
+func (c *Chamber) Grid() [][]*Slot {
+	return c.grid
+}
+
+// Slot looks up a slot by name, ignoring case.
+func (c *Chamber) Slot(name string) (*Slot, bool) {
+	if slot, ok := c.slots[name]; ok {
+		return slot, true
+	}
+	for key, slot := range c.slots {
+		if strings.EqualFold(key, name) {
+			return slot, true
+		}
+	}
+	return nil, false
+}
+
+// Location returns the Spoolman location string for the slot.
+func (s *Slot) Location() string {
+	return s.Chamber.LocationPrefix + s.Name
+}
+
+// LED returns the name of the LED lighting this slot, or "" if none does.
+func (s *Slot) LED() string {
+	if !s.HasLED {
+		return ""
+	}
+	return s.Location()
+}
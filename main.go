@@ -1,60 +1,216 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/tryy3/filament-chamber/config"
+	"github.com/tryy3/filament-chamber/events"
 	"github.com/tryy3/filament-chamber/handlers"
+	"github.com/tryy3/filament-chamber/hub"
+	"github.com/tryy3/filament-chamber/manager"
+	"github.com/tryy3/filament-chamber/outbox"
+	"github.com/tryy3/filament-chamber/scale"
+	"github.com/tryy3/filament-chamber/simulator"
+	"github.com/tryy3/filament-chamber/spoolman"
+	"github.com/tryy3/filament-chamber/transfers"
 )
 
 func main() {
-	// // Initialize the LED manager
-	// manager := manager.NewManager()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
+	log.Printf("Loaded configuration:\n%s", cfg)
+
+	chambers, err := cfg.Layout()
+	if err != nil {
+		log.Fatal("Invalid chamber layout: ", err)
+	}
+
+	// Live updates for the browsers
+	live := hub.New()
+
+	// Initialize the LED manager
+	leds, err := manager.NewManager(cfg.LEDServers(chambers), manager.Options{
+		FrameRate: cfg.LEDs.FrameRate,
+		OnSent: func(state manager.PinState) {
+			live.Retain(hub.TypeLEDs, fmt.Sprintf("%s/%d", state.Address, state.Pin), state)
+		},
+	})
+	if err != nil {
+		log.Fatal("Failed to create LED manager: ", err)
+	}
 
-	// // Example LED updates (keep for now, can be triggered via web later)
-	// manager.UpdateLED("B10", []int{255, 0, 0})
-	// manager.UpdateLED("B9", []int{255, 0, 0})
-	// manager.UpdateLED("B8", []int{255, 0, 0})
-	// manager.UpdateLED("B7", []int{255, 0, 0})
-	// manager.UpdateLED("B6", []int{255, 0, 0})
-	// manager.SendUpdateToServers()
+	// Connect to Spoolman
+	spoolmanService, err := spoolman.NewService(cfg.SpoolmanService())
+	if err != nil {
+		log.Fatal("Failed to create Spoolman client: ", err)
+	}
+
+	// Stand in for the LED controllers when simulating
+	var sim *simulator.Simulator
+	if cfg.LEDs.Simulate {
+		sim = simulator.New()
+		log.Printf("Simulating LED controllers, see /admin")
+	}
+
+	// Durable store for location transfers
+	box, err := outbox.New(cfg.Transfer.Outbox.Dir, cfg.OutboxOptions())
+	if err != nil {
+		log.Fatal("Failed to open transfer outbox: ", err)
+	}
+
+	// Kafka producer for transfers
+	var transferEvents *events.Producer
+	if cfg.Transfer.Mode == handlers.TransferModeKafka {
+		proxy, topic := cfg.TransferEvents()
+		transferEvents = events.NewClient(proxy).NewProducer(topic, events.ProducerOptions{})
+		go transferEvents.Run(context.Background())
+	}
+
+	// Spool list shared by the pages, refreshed after changes
+	spools := spoolman.NewCache(spoolmanService, cfg.Spoolman.CacheTTL)
+
+	// Outcomes of initiated transfers, pushed to the browser that started them
+	tracker := transfers.New(transfers.Options{
+		Timeout: cfg.Transfer.Results.Timeout,
+		OnChange: func(t transfers.Transfer) {
+			live.Publish(hub.TypeTransfer, t)
+			if t.Status == transfers.StatusCompleted {
+				spools.Invalidate()
+				live.Publish(hub.TypeSpool, hub.SpoolChange{ID: t.SpoolID, Location: t.Location})
+			}
+		},
+	})
+	go tracker.Run(context.Background())
+	if proxy, topic, ok := cfg.TransferResults(); ok {
+		go tracker.Consume(context.Background(), events.NewClient(proxy), cfg.Transfer.Results.Group, topic)
+	} else if cfg.Transfer.Mode == handlers.TransferModeKafka {
+		go tracker.Watch(context.Background(), cfg.Transfer.Results.PollInterval, func(ctx context.Context, spoolID int) (string, error) {
+			spool, err := spoolmanService.GetSpool(ctx, spoolID)
+			if err != nil {
+				return "", err
+			}
+			return spoolman.GetSpoolLocation(*spool), nil
+		})
+	}
+
+	// Weights from networked scales, paired with the spool scanned last
+	scaleOpts := cfg.ScaleOptions()
+	scaleOpts.OnState = func(state scale.State) {
+		live.Retain(hub.TypeScale, state.Scale, state)
+	}
+	scales := scale.New(scaleOpts)
+	if mqttCfg, ok := cfg.ScaleMQTT(); ok {
+		go scales.Subscribe(context.Background(), mqttCfg)
+	}
+	var fakeScale *scale.Fake
+	if cfg.Scale.Simulate {
+		fakeScale = scale.NewFake(scales)
+		log.Printf("Simulating a scale, see POST /simulator/scale/{scale}/load")
+	}
+
+	h := handlers.New(handlers.Deps{
+		Spoolman:       spoolmanService,
+		Spools:         spools,
+		Layout:         chambers,
+		LEDs:           leds,
+		Sim:            sim,
+		Outbox:         box,
+		TransferEvents: transferEvents,
+		Transfers:      tracker,
+		Live:           live,
+		Scales:         scales,
+	}, handlers.Config{
+		TransferMode:      cfg.Transfer.Mode,
+		TransferTimeout:   cfg.Transfer.Timeout,
+		LocateDuration:    cfg.LEDs.LocateDuration,
+		LocateBlink:       cfg.LEDs.LocateBlink,
+		FilterLEDs:        cfg.LEDs.Filter.Enabled,
+		FilterIdleTimeout: cfg.LEDs.Filter.IdleTimeout,
+		FilterDim:         cfg.LEDs.Filter.Dim,
+	})
+
+	// Deliver stored transfers in the background
+	go box.Run(context.Background(), h.DeliverTransfer)
+
+	// Record weighed spools in Spoolman
+	go scales.Run(context.Background(), h.RecordWeighing)
+
+	// Follow changes made in Spoolman
+	if cfg.Spoolman.Subscribe {
+		feed, err := spoolmanService.Subscriber(spoolman.SubscriberOptions{
+			OnConnect: h.SpoolmanReconnected,
+		})
+		if err != nil {
+			log.Fatal("Failed to subscribe to Spoolman: ", err)
+		}
+		go feed.Run(context.Background(), h.HandleSpoolmanEvent)
+	}
 
 	// Set up HTTP routes
 	mux := http.NewServeMux()
 
 	// Home page
-	mux.HandleFunc("/", handlers.HomeHandler)
+	mux.HandleFunc("/", h.HomeHandler)
 
 	// Spool management page
-	mux.HandleFunc("/spool", handlers.SpoolHandler)
+	mux.HandleFunc("/spool", h.SpoolHandler)
 	// Spool detail page (must be more specific than /spool)
-	mux.HandleFunc("/spool/", handlers.SpoolDetailHandler)
+	mux.HandleFunc("/spool/", h.SpoolDetailHandler)
+	mux.HandleFunc("GET /spool/{id}/weigh", h.WeighPageHandler)
 	// Admin/testing tools page
-	mux.HandleFunc("/admin", handlers.AdminHandler)
+	mux.HandleFunc("/admin", h.AdminHandler)
+	mux.HandleFunc("GET /admin/outbox", h.OutboxHandler)
 
 	// API endpoints
-	mux.HandleFunc("/api/demo", handlers.DemoHandler)
-	mux.HandleFunc("/api/spools", handlers.SpoolsAPIHandler)
-	mux.HandleFunc("/api/spools/filters", handlers.FilterMetadataHandler)
-	mux.HandleFunc("/api/spool/", handlers.SpoolJSONHandler)
-	mux.HandleFunc("/api/transfer-location", handlers.TransferLocationHandler)
+	mux.HandleFunc("/api/demo", h.DemoHandler)
+	mux.HandleFunc("/api/spools", h.SpoolsAPIHandler)
+	mux.HandleFunc("/api/spools/filters", h.FilterMetadataHandler)
+	mux.HandleFunc("POST /api/spools/register", h.RegisterSpoolHandler)
+	mux.HandleFunc("/api/spool/", h.SpoolJSONHandler)
+	mux.HandleFunc("POST /api/spool/{id}/locate", h.LocateSpoolHandler)
+	mux.HandleFunc("PATCH /api/spool/{id}", h.UpdateSpoolHandler)
+	mux.HandleFunc("POST /api/spool/{id}/use", h.UseSpoolHandler)
+	mux.HandleFunc("POST /api/spool/{id}/weigh", h.WeighSpoolHandler)
+	mux.HandleFunc("/api/transfer-location", h.TransferLocationHandler)
+	mux.HandleFunc("GET /api/transfers/{id}", h.TransferStatusHandler)
+	mux.HandleFunc("GET /api/events", h.EventsHandler)
+	mux.HandleFunc("POST /api/sensors", h.SensorHandler)
+	mux.HandleFunc("GET /api/scale", h.ScaleStateHandler)
+	mux.HandleFunc("POST /api/scale/readings", h.ScaleReadingHandler)
+	mux.HandleFunc("POST /api/scale/scan", h.ScaleScanHandler)
+	mux.HandleFunc("POST /api/outbox/{id}/retry", h.RetryOutboxHandler)
+	mux.HandleFunc("POST /api/outbox/{id}/discard", h.DiscardOutboxHandler)
+	mux.HandleFunc("GET /api/simulator/leds", h.SimulatorStateHandler)
+
+	// Simulated LED controllers
+	if sim != nil {
+		mux.HandleFunc("POST /simulator/{controller}/led", sim.LEDHandler)
+	}
+	// Fake scale
+	if fakeScale != nil {
+		mux.HandleFunc("POST /simulator/scale/{scale}/load", fakeScale.Handler)
+	}
 
 	// Static files (CSS, JS)
 	fs := http.FileServer(http.Dir("./static"))
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
 	// Start the server
-	log.Printf("Starting server on http://localhost:%s", port)
+	log.Printf("Starting server on http://localhost:%d", cfg.Server.Port)
 	log.Printf("Press Ctrl+C to stop")
 
-	err := http.ListenAndServe(":"+port, mux)
+	err = http.ListenAndServe(fmt.Sprintf(":%d", cfg.Server.Port), mux)
 	if err != nil {
 		log.Fatal("Server failed to start: ", err)
 	}
@@ -0,0 +1,212 @@
+package manager
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DefaultFrameRate is the number of animation frames computed per second
+// when the manager is not configured otherwise.
+const DefaultFrameRate = 10
+
+// PriorityLocate is the priority of Locate effects. Effects with a higher
+// priority are drawn on top of lower ones; all of them are drawn on top of
+// the frame set with ShowFrame.
+const PriorityLocate = 100
+
+// Effect computes LED colors over time. Render is called once per frame with
+// the time since the effect started and returns the colors of the LEDs it
+// drives; LEDs it leaves out show whatever is below the effect.
+type Effect interface {
+	Render(t time.Duration) map[string][]int
+}
+
+// PlayOptions controls how an effect is layered and how long it runs.
+type PlayOptions struct {
+	// Priority orders overlapping effects, higher wins.
+	Priority int
+	// Duration stops the effect automatically. Zero runs until cancelled.
+	Duration time.Duration
+}
+
+// Animation is a running effect.
+type Animation struct {
+	m       *Manager
+	effect  Effect
+	opts    PlayOptions
+	started time.Time
+	seq     int
+	done    chan struct{}
+}
+
+// Cancel stops the effect. The LEDs it drove fall back to the layer below
+// on the next frame. Cancelling a finished animation does nothing.
+func (a *Animation) Cancel() {
+	a.m.mu.Lock()
+	removed := a.m.removeAnimation(a)
+	a.m.mu.Unlock()
+	if removed {
+		a.m.kickEngine()
+	}
+}
+
+// Done is closed when the animation has finished or was cancelled.
+func (a *Animation) Done() <-chan struct{} {
+	return a.done
+}
+
+// Play starts an effect and returns a handle to cancel it. Frames are
+// rendered at the manager frame rate and streamed to the controllers.
+func (m *Manager) Play(effect Effect, opts PlayOptions) *Animation {
+	m.mu.Lock()
+	m.animSeq++
+	a := &Animation{
+		m:       m,
+		effect:  effect,
+		opts:    opts,
+		started: time.Now(),
+		seq:     m.animSeq,
+		done:    make(chan struct{}),
+	}
+	m.animations = append(m.animations, a)
+	m.mu.Unlock()
+
+	m.kickEngine()
+	return a
+}
+
+// removeAnimation drops a from the running set. m.mu must be held.
+func (m *Manager) removeAnimation(a *Animation) bool {
+	i := slices.Index(m.animations, a)
+	if i < 0 {
+		return false
+	}
+	m.animations = slices.Delete(m.animations, i, i+1)
+	close(a.done)
+	return true
+}
+
+// kickEngine makes sure the render loop is running.
+func (m *Manager) kickEngine() {
+	m.mu.Lock()
+	if m.engineRunning {
+		m.mu.Unlock()
+		return
+	}
+	m.engineRunning = true
+	m.mu.Unlock()
+
+	go m.runEngine()
+}
+
+func (m *Manager) runEngine() {
+	ticker := time.NewTicker(time.Second / time.Duration(m.frameRate))
+	defer ticker.Stop()
+	for {
+		active := m.renderAnimations(time.Now())
+		for _, result := range m.SendUpdateToServers(context.Background()) {
+			if !result.OK() {
+				slog.Error("Error streaming animation frame", "server", result.Address, "error", result.Error)
+			}
+		}
+		if !active {
+			m.mu.Lock()
+			// An effect may have been started while we were sending.
+			if len(m.animations) == 0 {
+				m.engineRunning = false
+				m.mu.Unlock()
+				return
+			}
+			m.mu.Unlock()
+		}
+		<-ticker.C
+	}
+}
+
+// renderAnimations computes the effect overlay for now, dropping finished
+// effects. It reports whether any effect is still running.
+func (m *Manager) renderAnimations(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range slices.Clone(m.animations) {
+		if a.opts.Duration > 0 && now.Sub(a.started) >= a.opts.Duration {
+			m.removeAnimation(a)
+		}
+	}
+
+	ordered := slices.Clone(m.animations)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].opts.Priority != ordered[j].opts.Priority {
+			return ordered[i].opts.Priority < ordered[j].opts.Priority
+		}
+		return ordered[i].seq < ordered[j].seq
+	})
+
+	overlay := map[string][]int{}
+	for _, a := range ordered {
+		for name, color := range a.effect.Render(now.Sub(a.started)) {
+			overlay[name] = slices.Clone(color)
+		}
+	}
+	m.overlay = overlay
+	return len(m.animations) > 0
+}
+
+// StripOf returns the LEDs on the same strip as ledName, in strip order.
+func (m *Manager) StripOf(ledName string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, server := range m.servers {
+		for _, pin := range server.PINs {
+			names := []string{}
+			found := false
+			for _, led := range pin.LEDs {
+				if !led.Active {
+					continue
+				}
+				names = append(names, led.Name)
+				found = found || led.Name == ledName
+			}
+			if found {
+				return names
+			}
+		}
+	}
+	return nil
+}
+
+func fill(leds []string, color []int) map[string][]int {
+	out := make(map[string][]int, len(leds))
+	for _, led := range leds {
+		out[led] = color
+	}
+	return out
+}
+
+// Solid lights LEDs in a fixed color.
+type Solid struct {
+	LEDs  []string
+	Color []int
+}
+
+func (e Solid) Render(t time.Duration) map[string][]int {
+	return fill(e.LEDs, e.Color)
+}
+
+// Blink alternates LEDs between Color and off, Period being one on/off cycle.
+type Blink struct {
+	LEDs   []string
+	Color  []int
+	Period time.Duration
+}
+
+func (e Blink) Render(t time.Duration) map[string][]int {
+	if e.Period <= 0 || t%e.Period < e.Period/2 {
+		return fill(e.LEDs, e.Color)
+	}
+	return fill(e.LEDs, Off)
+}
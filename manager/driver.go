@@ -0,0 +1,213 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Driver names accepted in ServerConfig.Driver.
+const (
+	DriverHTTP = "http"
+	DriverWLED = "wled"
+	DriverMQTT = "mqtt"
+)
+
+// Driver pushes LED frames to one controller using its firmware's protocol.
+type Driver interface {
+	// SendPin sets every LED on a pin (strip), in strip order. The context
+	// carries the per-request timeout.
+	SendPin(ctx context.Context, pin int, colors [][]int) error
+}
+
+// NewDriver creates the driver selected by cfg.Driver. An empty driver
+// selects the HTTP protocol.
+func NewDriver(cfg ServerConfig, client *http.Client) (Driver, error) {
+	switch cfg.Driver {
+	case "", DriverHTTP:
+		return &HTTPDriver{Address: cfg.Address, Client: client}, nil
+	case DriverWLED:
+		return &WLEDDriver{Address: cfg.Address, Client: client}, nil
+	case DriverMQTT:
+		return NewMQTTDriver(cfg.Address, cfg.MQTT)
+	default:
+		return nil, fmt.Errorf("unknown LED driver %q", cfg.Driver)
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	jsonReq, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonReq))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("controller returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// HTTPDriver speaks the Filament Chamber controller protocol:
+// POST {Address}/led with {"pin": n, "colors": [[r,g,b], ...]}.
+type HTTPDriver struct {
+	Address string
+	Client  *http.Client
+}
+
+func (d *HTTPDriver) SendPin(ctx context.Context, pin int, colors [][]int) error {
+	return postJSON(ctx, d.Client, d.Address+"/led", map[string]interface{}{
+		"pin":    pin,
+		"colors": colors,
+	})
+}
+
+// WLEDDriver drives a WLED controller through its JSON API. Each pin is a
+// WLED segment ID and the colors are set with the segment's individual LED
+// ("i") array, starting at the first LED of the segment.
+type WLEDDriver struct {
+	Address string
+	Client  *http.Client
+}
+
+type wledState struct {
+	On  bool          `json:"on"`
+	Seg []wledSegment `json:"seg"`
+}
+
+type wledSegment struct {
+	ID int     `json:"id"`
+	I  [][]int `json:"i"`
+}
+
+func (d *WLEDDriver) SendPin(ctx context.Context, pin int, colors [][]int) error {
+	return postJSON(ctx, d.Client, d.Address+"/json/state", wledState{
+		On:  true,
+		Seg: []wledSegment{{ID: pin, I: colors}},
+	})
+}
+
+// MQTTConfig holds the MQTT driver settings. The controller address is the
+// broker URL, e.g. tcp://192.168.1.10:1883.
+type MQTTConfig struct {
+	// Topic frames are published to. "{pin}" is replaced by the pin number.
+	Topic    string
+	ClientID string
+	Username string
+	Password string
+	QoS      byte
+	// Retain keeps the last frame on the broker for controllers that reconnect.
+	Retain bool
+}
+
+// MQTTDriver publishes {"pin": n, "colors": [...]} frames to a broker topic.
+// It connects lazily and reconnects automatically.
+type MQTTDriver struct {
+	cfg    MQTTConfig
+	client mqtt.Client
+
+	// connecting is the first connection attempt, or the latest after
+	// failed ones. Once connected the client reconnects by itself.
+	mu         sync.Mutex
+	connecting mqtt.Token
+}
+
+func NewMQTTDriver(broker string, cfg MQTTConfig) (*MQTTDriver, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("mqtt driver: topic is required")
+	}
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt driver: invalid QoS %d", cfg.QoS)
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "filament-chamber-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(false)
+	return &MQTTDriver{
+		cfg:    cfg,
+		client: mqtt.NewClient(opts),
+	}, nil
+}
+
+// connect waits for the connection to the broker. A send that gives up
+// waiting leaves the attempt running for the next one to wait on, so
+// attempts don't pile up while the broker is slow; only a failed attempt
+// is started over.
+func (d *MQTTDriver) connect(ctx context.Context) error {
+	d.mu.Lock()
+	token := d.connecting
+	if token == nil || failed(token) {
+		token = d.client.Connect()
+		d.connecting = token
+	}
+	d.mu.Unlock()
+
+	if err := waitToken(ctx, token); err != nil {
+		return fmt.Errorf("connecting to broker: %w", err)
+	}
+	return nil
+}
+
+// failed reports whether an MQTT operation completed with an error.
+func failed(token mqtt.Token) bool {
+	select {
+	case <-token.Done():
+		return token.Error() != nil
+	default:
+		return false
+	}
+}
+
+func (d *MQTTDriver) SendPin(ctx context.Context, pin int, colors [][]int) error {
+	if err := d.connect(ctx); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"pin":    pin,
+		"colors": colors,
+	})
+	if err != nil {
+		return err
+	}
+	topic := strings.ReplaceAll(d.cfg.Topic, "{pin}", strconv.Itoa(pin))
+	return waitToken(ctx, d.client.Publish(topic, d.cfg.QoS, d.cfg.Retain, payload))
+}
+
+// waitToken waits for an MQTT operation until it completes or ctx ends.
+func waitToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
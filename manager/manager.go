@@ -1,62 +1,349 @@
 package manager
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"io"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Off is the color of an unlit LED.
+var Off = []int{0, 0, 0}
+
+// locateBlinkPeriod is one on/off cycle of a blinking locate.
+const locateBlinkPeriod = time.Second
+
+// DefaultRequestTimeout bounds a single request to a controller when
+// ServerConfig.Timeout is zero.
+const DefaultRequestTimeout = 2 * time.Second
+
+// Manager keeps the desired color of every LED and pushes changes to the
+// controllers. It is safe for concurrent use.
+//
+// Updates only change the in-memory state; SendUpdateToServers pushes it.
+// Each push sends one frame per pin holding the latest colors, and only for
+// pins whose colors differ from what the controller last acknowledged, so
+// many rapid UpdateLED calls followed by one push cost one request per
+// changed pin.
+//
+// Effects started with Play are drawn on top of the colors set with
+// UpdateLED and ShowFrame; see animation.go.
 type Manager struct {
-	servers []*Server
+	// mu guards the LED state below.
+	mu        sync.Mutex
+	servers   []*Server
+	locates   map[string]*Animation
+	idleTimer *time.Timer
+
+	// Animation state: running effects and the colors they currently produce.
+	animations    []*Animation
+	animSeq       int
+	overlay       map[string][]int
+	engineRunning bool
+	frameRate     int
+
+	// locateMu serializes Locate so restarts of the same LED don't race.
+	locateMu sync.Mutex
+
+	client *http.Client
+	onSent func(PinState)
 }
 
-func (m *Manager) UpdateLED(ledName string, color []int) {
+// ServerResult reports the outcome of pushing frames to one controller.
+type ServerResult struct {
+	Address string
+	// Pins lists the pins a frame was sent for. Empty when nothing changed.
+	Pins  []int
+	Error error
+}
+
+// OK reports whether every frame was accepted.
+func (r ServerResult) OK() bool {
+	return r.Error == nil
+}
+
+// MarshalJSON includes the error message, which encoding/json cannot do for
+// the error interface.
+func (r ServerResult) MarshalJSON() ([]byte, error) {
+	errMsg := ""
+	if r.Error != nil {
+		errMsg = r.Error.Error()
+	}
+	return json.Marshal(struct {
+		Address string `json:"address"`
+		Pins    []int  `json:"pins"`
+		OK      bool   `json:"ok"`
+		Error   string `json:"error,omitempty"`
+	}{r.Address, r.Pins, r.OK(), errMsg})
+}
+
+// HasLED reports whether an LED with the given name is configured.
+func (m *Manager) HasLED(ledName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.findLED(ledName)
+	return ok
+}
+
+// findLED returns the named LED. m.mu must be held.
+func (m *Manager) findLED(ledName string) (*LED, bool) {
 	for _, server := range m.servers {
 		for i := range server.PINs {
 			for j := range server.PINs[i].LEDs {
-				if server.PINs[i].LEDs[j].Name == ledName {
-					server.PINs[i].LEDs[j].Color = color
-					break
+				led := &server.PINs[i].LEDs[j]
+				if led.Active && led.Name == ledName {
+					return led, true
 				}
 			}
 		}
 	}
+	return nil, false
+}
+
+func (m *Manager) UpdateLED(ledName string, color []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if led, ok := m.findLED(ledName); ok {
+		led.Color = slices.Clone(color)
+	}
+}
+
+type pinFrame struct {
+	server *Server
+	pin    int
+	colors [][]int
+}
+
+// SendUpdateToServers pushes every pin whose colors changed since the last
+// acknowledged frame. Controllers are updated in parallel, each request
+// bounded by the controller timeout. The result has one entry per
+// controller, in configuration order.
+func (m *Manager) SendUpdateToServers(ctx context.Context) []ServerResult {
+	results := make([]ServerResult, len(m.servers))
+	var wg sync.WaitGroup
+	for i, server := range m.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = m.sendChanged(ctx, server)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// sendChanged pushes the changed pins of one controller. Pushes to the same
+// controller wait for each other, so frames for a pin are never reordered,
+// while a slow controller does not hold up the others.
+func (m *Manager) sendChanged(ctx context.Context, server *Server) ServerResult {
+	server.sendMu.Lock()
+	defer server.sendMu.Unlock()
+
+	// Snapshot the changed pins so updates can continue while we send.
+	m.mu.Lock()
+	var frames []pinFrame
+	for _, pin := range server.PINs {
+		colors := m.pinColors(&pin)
+		if slices.EqualFunc(colors, pin.sent, slices.Equal[[]int]) {
+			continue
+		}
+		frames = append(frames, pinFrame{server: server, pin: pin.Pin, colors: colors})
+	}
+	m.mu.Unlock()
+
+	if len(frames) == 0 {
+		return ServerResult{Address: server.Adress, Pins: []int{}}
+	}
+	return m.sendFrames(ctx, server, frames)
+}
+
+// sendFrames posts the frames for one controller, one pin at a time.
+func (m *Manager) sendFrames(ctx context.Context, server *Server, frames []pinFrame) ServerResult {
+	result := ServerResult{Address: server.Adress, Pins: []int{}}
+	var errs []string
+	for _, frame := range frames {
+		result.Pins = append(result.Pins, frame.pin)
+		if err := m.post(ctx, server, frame); err != nil {
+			slog.Error("Error sending update to server", "server", server.Adress, "pin", frame.pin, "error", err)
+			errs = append(errs, fmt.Sprintf("pin %d: %v", frame.pin, err))
+			continue
+		}
+		m.mu.Lock()
+		var state PinState
+		if pin := server.pin(frame.pin); pin != nil {
+			pin.sent = frame.colors
+			state = pinState(server, pin)
+		}
+		m.mu.Unlock()
+		if m.onSent != nil && state.LEDs != nil {
+			m.onSent(state)
+		}
+	}
+	if len(errs) > 0 {
+		result.Error = fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return result
+}
+
+func (m *Manager) post(ctx context.Context, server *Server, frame pinFrame) error {
+	ctx, cancel := context.WithTimeout(ctx, server.Timeout)
+	defer cancel()
+
+	slog.Debug("Sending update to server", "server", server.Adress, "pin", frame.pin, "colors", frame.colors)
+	return server.driver.SendPin(ctx, frame.pin, frame.colors)
+}
+
+// Locate lights ledName in color for d, on top of anything else shown on
+// that LED. With blink set the LED alternates between color and off.
+// Locating an LED that is already being located restarts the timer with the
+// new color.
+//
+// The first frame is sent before Locate returns and its result is reported;
+// blinking and turning the LED off again happen in the background.
+func (m *Manager) Locate(ctx context.Context, ledName string, color []int, d time.Duration, blink bool) []ServerResult {
+	var effect Effect = Solid{LEDs: []string{ledName}, Color: color}
+	if blink {
+		effect = Blink{LEDs: []string{ledName}, Color: color, Period: locateBlinkPeriod}
+	}
+
+	m.locateMu.Lock()
+	defer m.locateMu.Unlock()
+
+	m.mu.Lock()
+	prev := m.locates[ledName]
+	m.mu.Unlock()
+	if prev != nil {
+		prev.Cancel()
+	}
+
+	a := m.Play(effect, PlayOptions{Priority: PriorityLocate, Duration: d})
+	m.mu.Lock()
+	m.locates[ledName] = a
+	m.mu.Unlock()
+	go func() {
+		<-a.Done()
+		m.mu.Lock()
+		if m.locates[ledName] == a {
+			delete(m.locates, ledName)
+		}
+		m.mu.Unlock()
+	}()
+
+	m.renderAnimations(time.Now())
+	return m.SendUpdateToServers(ctx)
+}
+
+// ShowFrame sets every LED to its color in frame, turning off LEDs that are
+// not in the frame, and pushes the result. Running effects such as a locate
+// stay on top. Unless another frame arrives first, all LEDs are turned off
+// again after idle.
+func (m *Manager) ShowFrame(ctx context.Context, frame map[string][]int, idle time.Duration) []ServerResult {
+	m.mu.Lock()
+	m.setFrame(frame, idle)
+	m.mu.Unlock()
+
+	return m.SendUpdateToServers(ctx)
 }
 
-func (m *Manager) SendUpdateToServers() {
+// setFrame sets the LED colors of ShowFrame and arms the idle timer. m.mu
+// must be held.
+func (m *Manager) setFrame(frame map[string][]int, idle time.Duration) {
 	for _, server := range m.servers {
-		for _, pin := range server.PINs {
-			colors := [][]int{}
-			for _, led := range pin.LEDs {
-				colors = append(colors, led.Color)
-			}
-			req := map[string]interface{}{
-				"pin":    pin.Pin,
-				"colors": colors,
-			}
-			jsonReq, _ := json.Marshal(req)
-			slog.Info("Sending update to server: ", "server", server.Adress, "pin", pin.Pin, "colors", colors, "jsonReq", string(jsonReq))
-			resp, err := http.Post(server.Adress+"/led", "application/json", bytes.NewBuffer(jsonReq))
-			if err != nil {
-				slog.Error("Error sending update to server: ", "error", err)
-				continue
-			}
-			defer resp.Body.Close()
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				slog.Error("Error reading response from server: ", "error", err)
-				continue
+		for i := range server.PINs {
+			for j := range server.PINs[i].LEDs {
+				led := &server.PINs[i].LEDs[j]
+				if !led.Active {
+					continue
+				}
+				if color, ok := frame[led.Name]; ok {
+					led.Color = slices.Clone(color)
+				} else {
+					led.Color = Off
+				}
 			}
-			slog.Info("Response from server: ", "body", string(body), "status", resp.StatusCode)
 		}
 	}
+	if m.idleTimer != nil {
+		m.idleTimer.Stop()
+		m.idleTimer = nil
+	}
+	if idle > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(idle, func() { m.idleClear(timer) })
+		m.idleTimer = timer
+	}
+}
+
+// idleClear turns off every LED once timer fires. Stop does not catch a
+// timer that already fired, so it does nothing unless timer is still the
+// current idle timer; otherwise it would blank a newer frame.
+func (m *Manager) idleClear(timer *time.Timer) {
+	m.mu.Lock()
+	if m.idleTimer != timer {
+		m.mu.Unlock()
+		return
+	}
+	m.setFrame(nil, 0)
+	m.mu.Unlock()
+
+	m.SendUpdateToServers(context.Background())
+}
+
+// Clear turns off every LED. Running effects are not affected.
+func (m *Manager) Clear(ctx context.Context) []ServerResult {
+	return m.ShowFrame(ctx, nil, 0)
+}
+
+// Dim scales color by factor (0 = off, 1 = unchanged).
+func Dim(color []int, factor float64) []int {
+	out := make([]int, len(color))
+	for i, c := range color {
+		out[i] = int(float64(c) * factor)
+	}
+	return out
+}
+
+// ParseHexColor converts "RRGGBB" or "#RRGGBB" into an RGB triple. An
+// alpha channel ("RRGGBBAA") is ignored.
+func ParseHexColor(hex string) ([]int, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 8 {
+		hex = hex[:6]
+	}
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid hex color %q", hex)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color %q", hex)
+	}
+	return []int{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, nil
 }
 
 type Server struct {
-	Adress string
-	PINs   []PIN
+	Adress  string
+	Timeout time.Duration
+	PINs    []PIN
+
+	driver Driver
+	// sendMu is held while frames are pushed to this controller.
+	sendMu sync.Mutex
+}
+
+// pin returns the PIN with the given number. The manager lock must be held.
+func (s *Server) pin(pin int) *PIN {
+	for i := range s.PINs {
+		if s.PINs[i].Pin == pin {
+			return &s.PINs[i]
+		}
+	}
+	return nil
 }
 
 func (s *Server) AddPINAndGenerateLEDs(pin int, leds []string, addEmptyBefore bool) {
@@ -91,6 +378,23 @@ func (s *Server) AddPINAndGenerateLEDs(pin int, leds []string, addEmptyBefore bo
 type PIN struct {
 	Pin  int
 	LEDs []LED
+
+	// sent holds the colors the controller last acknowledged, nil if none.
+	sent [][]int
+}
+
+// pinColors returns a copy of the colors to show on a pin in strip order,
+// with running effects drawn over the LED colors. m.mu must be held.
+func (m *Manager) pinColors(p *PIN) [][]int {
+	colors := make([][]int, 0, len(p.LEDs))
+	for _, led := range p.LEDs {
+		color := led.Color
+		if c, ok := m.overlay[led.Name]; ok && led.Active {
+			color = c
+		}
+		colors = append(colors, slices.Clone(color))
+	}
+	return colors
 }
 
 type LED struct {
@@ -99,15 +403,87 @@ type LED struct {
 	Name   string
 }
 
-func NewManager() *Manager {
-	server1 := &Server{
-		Adress: "http://192.168.1.243",
-		PINs:   []PIN{},
+// ServerConfig describes one LED controller and the LED strips wired to it.
+type ServerConfig struct {
+	// Address is the controller base URL, or the broker URL for MQTT.
+	Address string
+	// Driver selects the controller protocol: "http" (default), "wled" or "mqtt".
+	Driver string
+	// MQTT configures the MQTT driver.
+	MQTT MQTTConfig
+	// Timeout bounds each request to the controller.
+	Timeout time.Duration
+	PINs    []PINConfig
+}
+
+// PINConfig describes one LED strip attached to a controller pin.
+type PINConfig struct {
+	Pin int
+	// LEDs lists the slot names in strip order.
+	LEDs []string
+	// AddEmptyBefore places the unused LED before each named LED instead of after.
+	AddEmptyBefore bool
+}
+
+// PinState is what a controller pin shows after it accepted a frame.
+type PinState struct {
+	// Address is the controller address. It is not sent to browsers as it
+	// may contain credentials.
+	Address string `json:"-"`
+	Pin     int    `json:"pin"`
+	// LEDs maps the slot LED names on the pin to their colors.
+	LEDs map[string][]int `json:"leds"`
+}
+
+// pinState returns the acknowledged colors of pin. m.mu must be held.
+func pinState(server *Server, pin *PIN) PinState {
+	state := PinState{Address: server.Adress, Pin: pin.Pin, LEDs: map[string][]int{}}
+	for i, led := range pin.LEDs {
+		if led.Active && led.Name != "" && i < len(pin.sent) {
+			state.LEDs[led.Name] = slices.Clone(pin.sent[i])
+		}
 	}
-	server1.AddPINAndGenerateLEDs(2, []string{"B10", "B9", "B8", "B7", "B6"}, false)
-	server1.AddPINAndGenerateLEDs(5, []string{"A10", "A9", "A8", "A7", "A6"}, false)
+	return state
+}
+
+// Options tunes the manager.
+type Options struct {
+	// FrameRate is the number of animation frames per second.
+	FrameRate int
+	// OnSent is called after a controller accepted a frame for a pin. It
+	// must not block.
+	OnSent func(PinState)
+}
+
+func NewManager(configs []ServerConfig, opts Options) (*Manager, error) {
 	manager := &Manager{
-		servers: []*Server{server1},
+		servers:   []*Server{},
+		locates:   map[string]*Animation{},
+		frameRate: opts.FrameRate,
+		client:    &http.Client{},
+		onSent:    opts.OnSent,
+	}
+	if manager.frameRate <= 0 {
+		manager.frameRate = DefaultFrameRate
+	}
+	for _, cfg := range configs {
+		server := &Server{
+			Adress:  cfg.Address,
+			Timeout: cfg.Timeout,
+			PINs:    []PIN{},
+		}
+		if server.Timeout <= 0 {
+			server.Timeout = DefaultRequestTimeout
+		}
+		driver, err := NewDriver(cfg, manager.client)
+		if err != nil {
+			return nil, fmt.Errorf("controller %s: %w", cfg.Address, err)
+		}
+		server.driver = driver
+		for _, pin := range cfg.PINs {
+			server.AddPINAndGenerateLEDs(pin.Pin, pin.LEDs, pin.AddEmptyBefore)
+		}
+		manager.servers = append(manager.servers, server)
 	}
-	return manager
+	return manager, nil
 }
@@ -0,0 +1,461 @@
+// Package outbox is a durable, file-based queue of events that must reach
+// another system. Events are written to disk before they are acknowledged
+// and delivered by a background worker with exponential backoff, so nothing
+// is lost while the receiver is down.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the delivery state of an entry.
+type Status string
+
+const (
+	// StatusPending entries are waiting for their next delivery attempt.
+	StatusPending Status = "pending"
+	// StatusFailed entries were rejected or ran out of attempts. They are
+	// only retried manually.
+	StatusFailed Status = "failed"
+	// StatusDelivered entries are kept for the retention period.
+	StatusDelivered Status = "delivered"
+)
+
+// Entry is one queued event. The ID doubles as the idempotency key the
+// receiver can use to drop duplicate deliveries.
+type Entry struct {
+	ID string `json:"id"`
+	// Summary describes the event for humans, e.g. in the admin view.
+	Summary     string          `json:"summary"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	Created     time.Time       `json:"created"`
+	NextAttempt time.Time       `json:"next_attempt,omitzero"`
+	LastError   string          `json:"last_error,omitempty"`
+	Delivered   time.Time       `json:"delivered,omitzero"`
+	// Result is the receiver's response to the successful delivery.
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// DeliverFunc sends one entry to its receiver and returns the receiver's
+// response. Wrap errors that retrying cannot fix with Permanent.
+type DeliverFunc func(ctx context.Context, e Entry) (json.RawMessage, error)
+
+var (
+	// ErrNotFound is returned for unknown entry IDs.
+	ErrNotFound = errors.New("outbox entry not found")
+	// ErrBusy is returned when the entry is being delivered right now.
+	ErrBusy = errors.New("outbox entry is being delivered")
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a delivery error as final: the entry fails immediately
+// instead of being retried.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Options set how often and for how long an Outbox retries an entry, and
+// how long it keeps delivered ones. Unset fields use the Default values
+// below.
+type Options struct {
+	// MaxAttempts fails an entry after this many attempts.
+	MaxAttempts int
+	// MinBackoff is the delay after the first failed attempt, doubled after
+	// every further failure up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// AttemptTimeout bounds a single delivery by the worker.
+	AttemptTimeout time.Duration
+	// Retention is how long delivered entries are kept.
+	Retention time.Duration
+}
+
+const (
+	DefaultMaxAttempts    = 20
+	DefaultMinBackoff     = 2 * time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultAttemptTimeout = 10 * time.Second
+	DefaultRetention      = 7 * 24 * time.Hour
+)
+
+// Outbox stores entries as one JSON file each in a directory.
+type Outbox struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	inFlight map[string]bool
+	wake     chan struct{}
+}
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidID reports whether id can be used as an entry ID.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// NewID returns a random entry ID.
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// New opens the outbox in dir, creating it if needed, and loads the entries
+// left by a previous run.
+func New(dir string, opts Options) (*Outbox, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DefaultMaxBackoff, opts.MinBackoff)
+	}
+	if opts.AttemptTimeout <= 0 {
+		opts.AttemptTimeout = DefaultAttemptTimeout
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating outbox directory: %w", err)
+	}
+
+	o := &Outbox{
+		dir:      dir,
+		opts:     opts,
+		entries:  map[string]*Entry{},
+		inFlight: map[string]bool{},
+		wake:     make(chan struct{}, 1),
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading outbox entry: %w", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil || !ValidID(e.ID) {
+			slog.Error("Skipping unreadable outbox entry", "file", file, "error", err)
+			continue
+		}
+		o.entries[e.ID] = &e
+	}
+	return o, nil
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// save writes e atomically. o.mu must be held.
+func (o *Outbox) save(e *Entry) error {
+	raw, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(o.dir, e.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), o.path(e.ID))
+}
+
+// Enqueue durably stores a new pending entry. If an entry with the same ID
+// exists it is returned unchanged with existed set, which makes enqueueing
+// idempotent. Callers should attempt delivery right away with Deliver; the
+// worker picks the entry up after MinBackoff otherwise.
+func (o *Outbox) Enqueue(id, summary string, payload any) (e Entry, existed bool, err error) {
+	if !ValidID(id) {
+		return Entry{}, false, fmt.Errorf("invalid outbox entry id %q", id)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if existing, ok := o.entries[id]; ok {
+		return *existing, true, nil
+	}
+	now := time.Now()
+	entry := &Entry{
+		ID:          id,
+		Summary:     summary,
+		Payload:     raw,
+		Status:      StatusPending,
+		Created:     now,
+		NextAttempt: now.Add(o.opts.MinBackoff),
+	}
+	if err := o.save(entry); err != nil {
+		return Entry{}, false, fmt.Errorf("writing outbox entry: %w", err)
+	}
+	o.entries[id] = entry
+	o.notify()
+	return *entry, false, nil
+}
+
+// Deliver attempts to deliver an entry now and returns it with the outcome
+// recorded. Delivered and failed entries are returned as is: a failed entry
+// is only attempted again after Retry.
+func (o *Outbox) Deliver(ctx context.Context, id string, deliver DeliverFunc) (Entry, error) {
+	o.mu.Lock()
+	e, ok := o.entries[id]
+	if !ok {
+		o.mu.Unlock()
+		return Entry{}, ErrNotFound
+	}
+	if e.Status == StatusDelivered || e.Status == StatusFailed {
+		o.mu.Unlock()
+		return *e, nil
+	}
+	if o.inFlight[id] {
+		o.mu.Unlock()
+		return *e, ErrBusy
+	}
+	o.inFlight[id] = true
+	snapshot := *e
+	o.mu.Unlock()
+
+	result, deliverErr := deliver(ctx, snapshot)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.inFlight, id)
+	e, ok = o.entries[id]
+	if !ok {
+		// Discarded while delivering
+		return snapshot, deliverErr
+	}
+	now := time.Now()
+	e.Attempts++
+	if deliverErr == nil {
+		e.Status = StatusDelivered
+		e.Delivered = now
+		e.NextAttempt = time.Time{}
+		e.LastError = ""
+		e.Result = result
+	} else {
+		e.LastError = deliverErr.Error()
+		if o.Final(snapshot, deliverErr) {
+			e.Status = StatusFailed
+			e.NextAttempt = time.Time{}
+		} else {
+			e.Status = StatusPending
+			e.NextAttempt = now.Add(o.backoff(e.Attempts))
+			o.notify()
+		}
+	}
+	if err := o.save(e); err != nil {
+		slog.Error("Error saving outbox entry", "id", id, "error", err)
+	}
+	return *e, deliverErr
+}
+
+// Final reports whether the attempt to deliver e failing with err gives up
+// on it, so the delivery function can tell the entry's owner.
+func (o *Outbox) Final(e Entry, err error) bool {
+	return err != nil && (IsPermanent(err) || e.Attempts+1 >= o.opts.MaxAttempts)
+}
+
+// backoff is the delay after the given number of failed attempts.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.opts.MinBackoff
+	for i := 1; i < attempts && d < o.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, o.opts.MaxBackoff)
+}
+
+// Run delivers due entries until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, deliver DeliverFunc) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-o.wake:
+		}
+
+		for _, id := range o.due(time.Now()) {
+			attemptCtx, cancel := context.WithTimeout(ctx, o.opts.AttemptTimeout)
+			e, err := o.Deliver(attemptCtx, id, deliver)
+			cancel()
+			switch {
+			case err == nil:
+				slog.Info("Delivered outbox entry", "id", id, "summary", e.Summary, "attempts", e.Attempts)
+			case errors.Is(err, ErrBusy), errors.Is(err, ErrNotFound):
+			default:
+				slog.Warn("Outbox delivery failed", "id", id, "summary", e.Summary, "attempts", e.Attempts, "status", e.Status, "error", err)
+			}
+		}
+		o.prune(time.Now())
+		timer.Reset(o.untilNext(time.Now()))
+	}
+}
+
+func (o *Outbox) due(now time.Time) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ids := []string{}
+	for id, e := range o.entries {
+		if e.Status == StatusPending && !o.inFlight[id] && !e.NextAttempt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return o.entries[ids[i]].Created.Before(o.entries[ids[j]].Created)
+	})
+	return ids
+}
+
+// untilNext is how long the worker can sleep. It wakes at least once a
+// minute to prune delivered entries.
+func (o *Outbox) untilNext(now time.Time) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	next := time.Minute
+	for _, e := range o.entries {
+		if e.Status == StatusPending {
+			next = min(next, max(e.NextAttempt.Sub(now), 0))
+		}
+	}
+	return next
+}
+
+// prune removes delivered entries older than the retention period.
+func (o *Outbox) prune(now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for id, e := range o.entries {
+		if e.Status == StatusDelivered && now.Sub(e.Delivered) > o.opts.Retention {
+			if err := os.Remove(o.path(id)); err != nil && !os.IsNotExist(err) {
+				slog.Error("Error pruning outbox entry", "id", id, "error", err)
+				continue
+			}
+			delete(o.entries, id)
+		}
+	}
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Entries returns all entries, newest first.
+func (o *Outbox) Entries() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entries := make([]Entry, 0, len(o.entries))
+	for _, e := range o.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.After(entries[j].Created)
+		}
+		return strings.Compare(entries[i].ID, entries[j].ID) < 0
+	})
+	return entries
+}
+
+// Get returns one entry.
+func (o *Outbox) Get(id string) (Entry, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// Retry makes a pending or failed entry due immediately and wakes the
+// worker. The attempt counter starts over.
+func (o *Outbox) Retry(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.Status == StatusDelivered {
+		return fmt.Errorf("entry %s was already delivered", id)
+	}
+	e.Status = StatusPending
+	e.Attempts = 0
+	e.NextAttempt = time.Now()
+	if err := o.save(e); err != nil {
+		return err
+	}
+	o.notify()
+	return nil
+}
+
+// Discard deletes an entry without delivering it.
+func (o *Outbox) Discard(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.entries[id]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(o.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(o.entries, id)
+	return nil
+}
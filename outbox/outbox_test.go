@@ -0,0 +1,269 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type payload struct {
+	Spool int `json:"spool"`
+}
+
+func open(t *testing.T, dir string, opts Options) *Outbox {
+	t.Helper()
+	o, err := New(dir, opts)
+	if err != nil {
+		t.Fatalf("opening outbox: %v", err)
+	}
+	return o
+}
+
+func enqueue(t *testing.T, o *Outbox, id string) Entry {
+	t.Helper()
+	e, existed, err := o.Enqueue(id, "spool 12 to A1", payload{Spool: 12})
+	if err != nil {
+		t.Fatalf("enqueueing %s: %v", id, err)
+	}
+	if existed {
+		t.Fatalf("entry %s already existed", id)
+	}
+	return e
+}
+
+func spoolOf(t *testing.T, e Entry) int {
+	t.Helper()
+	var p payload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		t.Fatalf("entry %s payload: %v", e.ID, err)
+	}
+	return p.Spool
+}
+
+func deliverWith(err error) DeliverFunc {
+	return func(context.Context, Entry) (json.RawMessage, error) {
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(`{"offset": 4}`), nil
+	}
+}
+
+func TestEnqueueIsIdempotent(t *testing.T) {
+	o := open(t, t.TempDir(), Options{})
+	first := enqueue(t, o, "event-1")
+
+	again, existed, err := o.Enqueue("event-1", "something else", payload{Spool: 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !existed {
+		t.Error("second Enqueue did not report the existing entry")
+	}
+	if again.Summary != first.Summary || spoolOf(t, again) != 12 {
+		t.Errorf("second Enqueue changed the entry to %q %s", again.Summary, again.Payload)
+	}
+	if n := len(o.Entries()); n != 1 {
+		t.Errorf("outbox has %d entries, want 1", n)
+	}
+
+	if _, _, err := o.Enqueue("../escape", "", nil); err == nil {
+		t.Error("Enqueue accepted an invalid ID")
+	}
+}
+
+func TestEntriesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Options{})
+	enqueue(t, o, "event-1")
+	if _, err := o.Deliver(context.Background(), "event-1", deliverWith(errors.New("proxy down"))); err == nil {
+		t.Fatal("Deliver did not return the delivery error")
+	}
+
+	temps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(temps) > 0 {
+		t.Errorf("temporary files left behind: %v", temps)
+	}
+	// A crash while writing leaves a half written file that must not
+	// break loading.
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"id": "bro`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := open(t, dir, Options{})
+	e, ok := reopened.Get("event-1")
+	if !ok {
+		t.Fatal("entry lost after reopening")
+	}
+	if e.Status != StatusPending || e.Attempts != 1 || e.LastError != "proxy down" || spoolOf(t, e) != 12 {
+		t.Errorf("reloaded entry = %+v", e)
+	}
+	if n := len(reopened.Entries()); n != 1 {
+		t.Errorf("reopened outbox has %d entries, want 1", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	o := open(t, t.TempDir(), Options{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		20: 10 * time.Second,
+	} {
+		if got := o.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestFailedAttemptsAreScheduled(t *testing.T) {
+	o := open(t, t.TempDir(), Options{MinBackoff: time.Minute, MaxBackoff: time.Hour})
+	e := enqueue(t, o, "event-1")
+	if due := o.due(time.Now()); len(due) != 0 {
+		t.Errorf("new entry is due before MinBackoff: %v", due)
+	}
+	if due := o.due(e.NextAttempt); len(due) != 1 {
+		t.Errorf("new entry is not due after MinBackoff")
+	}
+
+	fail := deliverWith(errors.New("proxy down"))
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		before := time.Now()
+		e, _ = o.Deliver(context.Background(), "event-1", fail)
+		if e.Attempts != attempt+1 || e.Status != StatusPending {
+			t.Fatalf("after attempt %d: %d attempts, status %s", attempt+1, e.Attempts, e.Status)
+		}
+		if e.NextAttempt.Before(before.Add(wait)) || e.NextAttempt.After(time.Now().Add(wait)) {
+			t.Errorf("after attempt %d: next attempt in %s, want %s", attempt+1, e.NextAttempt.Sub(before), wait)
+		}
+	}
+	if wait := o.untilNext(e.NextAttempt.Add(-time.Second)); wait != time.Second {
+		t.Errorf("worker sleeps %s a second before the next attempt", wait)
+	}
+}
+
+func TestPermanentErrorsFailImmediately(t *testing.T) {
+	o := open(t, t.TempDir(), Options{})
+	e := enqueue(t, o, "event-1")
+	rejected := Permanent(errors.New("spool #12 does not exist"))
+	if !o.Final(e, rejected) {
+		t.Error("Final is false for a permanent error")
+	}
+
+	e, err := o.Deliver(context.Background(), "event-1", deliverWith(rejected))
+	if !IsPermanent(err) {
+		t.Errorf("Deliver returned %v, want the permanent error", err)
+	}
+	if e.Status != StatusFailed || e.Attempts != 1 || !e.NextAttempt.IsZero() {
+		t.Errorf("rejected entry = %+v", e)
+	}
+	if due := o.due(time.Now().Add(time.Hour)); len(due) != 0 {
+		t.Errorf("failed entry is still due: %v", due)
+	}
+
+	// Delivering it again, as for a resent event, leaves it alone
+	e, err = o.Deliver(context.Background(), "event-1", func(context.Context, Entry) (json.RawMessage, error) {
+		t.Error("failed entry was delivered again")
+		return nil, nil
+	})
+	if err != nil || e.Status != StatusFailed || e.Attempts != 1 {
+		t.Errorf("delivering the failed entry again = %+v, %v", e, err)
+	}
+}
+
+func TestRetryableErrorsFailAfterMaxAttempts(t *testing.T) {
+	o := open(t, t.TempDir(), Options{MaxAttempts: 3, MinBackoff: time.Millisecond})
+	enqueue(t, o, "event-1")
+	down := errors.New("proxy down")
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		e, _ := o.Get("event-1")
+		if final := o.Final(e, down); final != (attempt == 3) {
+			t.Errorf("attempt %d: Final = %t", attempt, final)
+		}
+		e, err := o.Deliver(context.Background(), "event-1", deliverWith(down))
+		if IsPermanent(err) {
+			t.Errorf("attempt %d: retryable error became permanent", attempt)
+		}
+		want := StatusPending
+		if attempt == 3 {
+			want = StatusFailed
+		}
+		if e.Status != want {
+			t.Errorf("attempt %d: status %s, want %s", attempt, e.Status, want)
+		}
+	}
+}
+
+func TestRetryAndDiscard(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Options{})
+	enqueue(t, o, "event-1")
+	o.Deliver(context.Background(), "event-1", deliverWith(Permanent(errors.New("rejected"))))
+
+	if err := o.Retry("event-1"); err != nil {
+		t.Fatalf("retrying a failed entry: %v", err)
+	}
+	e, _ := o.Get("event-1")
+	if e.Status != StatusPending || e.Attempts != 0 {
+		t.Errorf("retried entry = %+v", e)
+	}
+	if due := o.due(time.Now()); len(due) != 1 {
+		t.Error("retried entry is not due right away")
+	}
+
+	e, err := o.Deliver(context.Background(), "event-1", deliverWith(nil))
+	if err != nil || e.Status != StatusDelivered || string(e.Result) != `{"offset": 4}` {
+		t.Errorf("delivering the retried entry = %+v, %v", e, err)
+	}
+	if err := o.Retry("event-1"); err == nil {
+		t.Error("Retry accepted a delivered entry")
+	}
+
+	if err := o.Discard("event-1"); err != nil {
+		t.Fatalf("discarding: %v", err)
+	}
+	if _, ok := o.Get("event-1"); ok {
+		t.Error("discarded entry is still there")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "event-1.json")); !os.IsNotExist(err) {
+		t.Errorf("discarded entry file: %v", err)
+	}
+	if err := o.Discard("event-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("discarding twice = %v, want ErrNotFound", err)
+	}
+	if err := o.Retry("event-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("retrying a discarded entry = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Options{Retention: time.Hour})
+	enqueue(t, o, "delivered")
+	enqueue(t, o, "pending")
+	o.Deliver(context.Background(), "delivered", deliverWith(nil))
+
+	o.prune(time.Now().Add(30 * time.Minute))
+	if _, ok := o.Get("delivered"); !ok {
+		t.Error("delivered entry pruned before the retention period")
+	}
+
+	o.prune(time.Now().Add(2 * time.Hour))
+	if _, ok := o.Get("delivered"); ok {
+		t.Error("delivered entry kept after the retention period")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "delivered.json")); !os.IsNotExist(err) {
+		t.Errorf("pruned entry file: %v", err)
+	}
+	if _, ok := o.Get("pending"); !ok {
+		t.Error("pending entry was pruned")
+	}
+}
@@ -0,0 +1,105 @@
+package scale
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timing of the fake scale: a load wobbles for fakeSettle before it
+// settles, and readings stop after fakeDuration like a scale going idle.
+const (
+	fakeInterval = 200 * time.Millisecond
+	fakeSettle   = time.Second
+	fakeDuration = 10 * time.Second
+)
+
+// Fake is an in-process scale. Putting a weight on it streams noisy
+// readings to the monitor the way a load cell does, so the weigh-in can be
+// developed without the hardware.
+type Fake struct {
+	monitor *Monitor
+
+	mu sync.Mutex
+	// stop ends the readings of a scale's current load.
+	stop map[string]context.CancelFunc
+}
+
+func NewFake(m *Monitor) *Fake {
+	return &Fake{
+		monitor: m,
+		stop:    map[string]context.CancelFunc{},
+	}
+}
+
+type fakeRequest struct {
+	Grams *float64 `json:"grams"`
+	// Noise is the spread of the readings in grams, 0.5 by default.
+	Noise *float64 `json:"noise"`
+}
+
+// Handler puts a load on the named scale (POST /simulator/scale/{scale}/load)
+// with {"grams": 812, "noise": 0.5}. {"grams": 0} empties the scale.
+func (f *Fake) Handler(w http.ResponseWriter, r *http.Request) {
+	scale := r.PathValue("scale")
+
+	var req fakeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Grams == nil {
+		http.Error(w, "Missing grams", http.StatusBadRequest)
+		return
+	}
+	noise := 0.5
+	if req.Noise != nil {
+		if *req.Noise < 0 {
+			http.Error(w, "Noise must not be negative", http.StatusBadRequest)
+			return
+		}
+		noise = *req.Noise
+	}
+
+	f.put(scale, *req.Grams, noise)
+
+	w.WriteHeader(http.StatusAccepted)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.Printf("Error writing fake scale response: %v", err)
+	}
+}
+
+// put replaces the load on a scale and streams its readings.
+func (f *Fake) put(scale string, grams, noise float64) {
+	ctx, cancel := context.WithTimeout(context.Background(), fakeDuration)
+	f.mu.Lock()
+	if stop := f.stop[scale]; stop != nil {
+		stop()
+	}
+	f.stop[scale] = cancel
+	f.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		start := time.Now()
+		ticker := time.NewTicker(fakeInterval)
+		defer ticker.Stop()
+		for {
+			// The load swings while it is being put down
+			spread := noise
+			if time.Since(start) < fakeSettle {
+				spread = max(noise, grams*0.05)
+			}
+			f.monitor.Add(Reading{Scale: scale, Grams: grams + (rand.Float64()*2-1)*spread})
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
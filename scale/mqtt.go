@@ -0,0 +1,101 @@
+package scale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTConfig selects the broker and topic scales publish readings to.
+type MQTTConfig struct {
+	// Broker is the broker URL, e.g. tcp://192.168.1.10:1883.
+	Broker string
+	// Topic may contain wildcards, e.g. filament-chamber/scale/+.
+	Topic    string
+	ClientID string
+	Username string
+	Password string
+	QoS      byte
+}
+
+// Subscribe feeds the readings published to cfg.Topic to the monitor until
+// ctx ends, reconnecting when the broker goes away. A reading is either a
+// bare number of grams, named after the last topic level, or JSON like
+// {"scale": "bench", "grams": 812.4}; "weight" is accepted for "grams".
+func (m *Monitor) Subscribe(ctx context.Context, cfg MQTTConfig) {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "filament-chamber-scale-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	handle := func(_ mqtt.Client, msg mqtt.Message) {
+		r, err := parseMessage(msg.Topic(), msg.Payload())
+		if err != nil {
+			slog.Warn("skipping scale reading", "topic", msg.Topic(), "error", err)
+			return
+		}
+		m.Add(r)
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		// Subscriptions are lost with the connection
+		SetOnConnectHandler(func(c mqtt.Client) {
+			token := c.Subscribe(cfg.Topic, cfg.QoS, handle)
+			token.Wait()
+			if err := token.Error(); err != nil {
+				slog.Warn("subscribing to scale readings failed", "topic", cfg.Topic, "error", err)
+				return
+			}
+			slog.Info("subscribed to scale readings", "topic", cfg.Topic)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			slog.Warn("scale broker connection lost, reconnecting", "broker", cfg.Broker, "error", err)
+		})
+
+	client := mqtt.NewClient(opts)
+	client.Connect()
+	<-ctx.Done()
+	client.Disconnect(250)
+}
+
+// parseMessage reads a reading published to topic.
+func parseMessage(topic string, payload []byte) (Reading, error) {
+	r := Reading{Scale: topic[strings.LastIndex(topic, "/")+1:]}
+	text := strings.TrimSpace(string(payload))
+	if grams, err := strconv.ParseFloat(text, 64); err == nil && !math.IsNaN(grams) && !math.IsInf(grams, 0) {
+		r.Grams = grams
+		return r, nil
+	}
+
+	var msg struct {
+		Scale  string   `json:"scale"`
+		Grams  *float64 `json:"grams"`
+		Weight *float64 `json:"weight"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return Reading{}, fmt.Errorf("payload %q is neither a number nor JSON", text)
+	}
+	switch {
+	case msg.Grams != nil:
+		r.Grams = *msg.Grams
+	case msg.Weight != nil:
+		r.Grams = *msg.Weight
+	default:
+		return Reading{}, fmt.Errorf("payload %q has no grams", text)
+	}
+	if msg.Scale != "" {
+		r.Scale = msg.Scale
+	}
+	return r, nil
+}
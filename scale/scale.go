@@ -0,0 +1,268 @@
+// Package scale turns the readings of networked load cells into stable
+// weights and pairs them with the spool that was just scanned, so putting a
+// scanned spool on the scale is enough to weigh it in.
+//
+// Readings arrive by HTTP or MQTT (see Subscribe) and are smoothed with a
+// median filter. A weight is stable once the filtered value stays within
+// Tolerance for Settle; every stable weight is reported once, until the
+// load changes.
+package scale
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Monitor without other settings takes the median of five readings, calls
+// a load stable after 1.5 s within 2 g, ignores loads under 20 g and waits two
+// minutes for a scanned spool to be put on the scale.
+const (
+	DefaultWindow      = 5
+	DefaultTolerance   = 2.0
+	DefaultSettle      = 1500 * time.Millisecond
+	DefaultMinWeight   = 20.0
+	DefaultScanTimeout = 2 * time.Minute
+)
+
+// weighingBuffer is how many stable weights may wait for Run before new
+// ones are dropped.
+const weighingBuffer = 16
+
+// Reading is one raw reading of a scale, in grams.
+type Reading struct {
+	Scale string  `json:"scale"`
+	Grams float64 `json:"grams"`
+}
+
+// State is the filtered weight on a scale.
+type State struct {
+	Scale string  `json:"scale"`
+	Grams float64 `json:"grams"`
+	// Stable is set once the weight has settled.
+	Stable  bool      `json:"stable"`
+	Updated time.Time `json:"updated"`
+}
+
+// Weighing is a stable weight measured for a scanned spool.
+type Weighing struct {
+	Scale   string    `json:"scale"`
+	SpoolID int       `json:"spool_id"`
+	Grams   float64   `json:"grams"`
+	Time    time.Time `json:"time"`
+}
+
+// Scan is a spool waiting to be weighed.
+type Scan struct {
+	SpoolID int       `json:"spool_id"`
+	Scanned time.Time `json:"scanned"`
+}
+
+// Options set how readings are smoothed into stable weights and how long a
+// scan waits for one. Fields that are not set use the Default values above.
+type Options struct {
+	// Window is the number of readings the median is taken over.
+	Window int
+	// Tolerance is how far, in grams, the weight may wander and still count
+	// as the same load.
+	Tolerance float64
+	// Settle is how long the weight has to stay within Tolerance to be
+	// stable.
+	Settle time.Duration
+	// MinWeight is the lightest load in grams counted as a spool; anything
+	// less is an empty scale.
+	MinWeight float64
+	// ScanTimeout is how long a scanned spool waits to be put on a scale. A
+	// spool scanned while already on the scale is weighed if its weight
+	// settled within ScanTimeout too.
+	ScanTimeout time.Duration
+	// OnState is called from Add when the weight shown by a scale changes.
+	// It should only hand the state on, e.g. to the hub: a slow OnState
+	// holds up every scale, and one that reads from the Monitor deadlocks.
+	OnState func(State)
+}
+
+// filter smooths the readings of one scale.
+type filter struct {
+	readings []float64
+	// load is the weight the current load settled around and since when.
+	load  float64
+	since time.Time
+	// reported is set once the current load was reported as stable, and
+	// weighed once it was recorded for a scanned spool.
+	reported bool
+	weighed  bool
+	state    State
+}
+
+// Monitor keeps the state of every scale that sent a reading.
+type Monitor struct {
+	opts Options
+
+	mu      sync.Mutex
+	scales  map[string]*filter
+	pending *Scan
+
+	weighings chan Weighing
+}
+
+func New(opts Options) *Monitor {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = DefaultTolerance
+	}
+	if opts.Settle <= 0 {
+		opts.Settle = DefaultSettle
+	}
+	if opts.MinWeight <= 0 {
+		opts.MinWeight = DefaultMinWeight
+	}
+	if opts.ScanTimeout <= 0 {
+		opts.ScanTimeout = DefaultScanTimeout
+	}
+	return &Monitor{
+		opts:      opts,
+		scales:    map[string]*filter{},
+		weighings: make(chan Weighing, weighingBuffer),
+	}
+}
+
+// Add feeds a reading to its scale and returns the scale's new state. A
+// stable weight on the scale is paired with a pending scan, see Run.
+func (m *Monitor) Add(r Reading) State {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := m.scales[r.Scale]
+	if f == nil {
+		f = &filter{}
+		m.scales[r.Scale] = f
+	}
+	f.readings = append(f.readings, r.Grams)
+	if len(f.readings) > m.opts.Window {
+		f.readings = f.readings[len(f.readings)-m.opts.Window:]
+	}
+
+	grams := median(f.readings)
+	if f.since.IsZero() || math.Abs(grams-f.load) > m.opts.Tolerance {
+		f.load, f.since, f.reported, f.weighed = grams, now, false, false
+	}
+	stable := len(f.readings) == m.opts.Window && now.Sub(f.since) >= m.opts.Settle
+
+	state := State{Scale: r.Scale, Grams: math.Round(grams*10) / 10, Stable: stable, Updated: now}
+	// Only whole gram changes are shown, the rest is noise
+	if m.opts.OnState != nil && (stable != f.state.Stable || math.Round(grams) != math.Round(f.state.Grams)) {
+		m.opts.OnState(state)
+	}
+	f.state = state
+
+	if stable && !f.reported {
+		f.reported = true
+		if grams >= m.opts.MinWeight {
+			m.weigh(f, now)
+		}
+	}
+	return state
+}
+
+// Scanned marks a spool as waiting to be weighed, replacing any spool
+// scanned before. If a scale already holds a load that settled within
+// ScanTimeout and was not weighed for another spool, the spool is weighed
+// right away.
+func (m *Monitor) Scanned(spoolID int) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = &Scan{SpoolID: spoolID, Scanned: now}
+	// The scale that settled last is the one the spool was put on
+	var latest *filter
+	for _, f := range m.scales {
+		if f.state.Stable && !f.weighed && f.load >= m.opts.MinWeight && now.Sub(f.since) <= m.opts.ScanTimeout+m.opts.Settle {
+			if latest == nil || f.since.After(latest.since) {
+				latest = f
+			}
+		}
+	}
+	if latest != nil {
+		m.weigh(latest, now)
+	}
+}
+
+// weigh pairs a stable weight with the pending scan, if it is recent
+// enough. It is called with the monitor locked.
+func (m *Monitor) weigh(f *filter, now time.Time) {
+	if m.pending == nil {
+		return
+	}
+	scan := *m.pending
+	m.pending = nil
+	if now.Sub(scan.Scanned) > m.opts.ScanTimeout {
+		slog.Debug("scanned spool was not weighed in time", "spool_id", scan.SpoolID, "scanned", scan.Scanned)
+		return
+	}
+	f.weighed = true
+	w := Weighing{Scale: f.state.Scale, SpoolID: scan.SpoolID, Grams: f.state.Grams, Time: now}
+	select {
+	case m.weighings <- w:
+	default:
+		slog.Warn("dropping weighing, nothing is recording them", "spool_id", w.SpoolID, "grams", w.Grams)
+	}
+}
+
+// Run hands every weighing to record until ctx ends.
+func (m *Monitor) Run(ctx context.Context, record func(context.Context, Weighing)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case w := <-m.weighings:
+			record(ctx, w)
+		}
+	}
+}
+
+// States returns the state of every scale, ordered by name.
+func (m *Monitor) States() []State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	states := make([]State, 0, len(m.scales))
+	for _, f := range m.scales {
+		states = append(states, f.state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Scale < states[j].Scale
+	})
+	return states
+}
+
+// Pending returns the spool waiting to be weighed, if any.
+func (m *Monitor) Pending() (Scan, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pending == nil || time.Since(m.pending.Scanned) > m.opts.ScanTimeout {
+		return Scan{}, false
+	}
+	return *m.pending, true
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
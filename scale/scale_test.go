@@ -0,0 +1,213 @@
+package scale
+
+import (
+	"testing"
+	"time"
+)
+
+const settle = 30 * time.Millisecond
+
+func newMonitor(opts Options) *Monitor {
+	if opts.Window == 0 {
+		opts.Window = 3
+	}
+	if opts.Settle == 0 {
+		opts.Settle = settle
+	}
+	return New(opts)
+}
+
+// load puts grams on the scale and keeps it there until it is stable.
+func load(t *testing.T, m *Monitor, scale string, grams float64) State {
+	t.Helper()
+	for range m.opts.Window {
+		m.Add(Reading{Scale: scale, Grams: grams})
+	}
+	time.Sleep(m.opts.Settle + 10*time.Millisecond)
+	state := m.Add(Reading{Scale: scale, Grams: grams})
+	if !state.Stable {
+		t.Fatalf("%g g on %s is not stable after settling", grams, scale)
+	}
+	return state
+}
+
+// weighing returns the weighing waiting for Run, if any.
+func weighing(m *Monitor) (Weighing, bool) {
+	select {
+	case w := <-m.weighings:
+		return w, true
+	default:
+		return Weighing{}, false
+	}
+}
+
+func TestMedian(t *testing.T) {
+	for _, tc := range []struct {
+		values []float64
+		want   float64
+	}{
+		{[]float64{500}, 500},
+		{[]float64{500, 502}, 501},
+		{[]float64{500, 2000, 499}, 500},
+		{[]float64{0, 500, 501, 502, 3000}, 501},
+	} {
+		if got := median(tc.values); got != tc.want {
+			t.Errorf("median(%v) = %g, want %g", tc.values, got, tc.want)
+		}
+	}
+}
+
+func TestMedianWindow(t *testing.T) {
+	m := newMonitor(Options{Tolerance: 2})
+	load(t, m, "bench", 500)
+
+	// One spike is outvoted by the rest of the window
+	if s := m.Add(Reading{Scale: "bench", Grams: 900}); s.Grams != 500 || !s.Stable {
+		t.Errorf("after a spike: %+v, want a stable 500 g", s)
+	}
+	// Two readings are the majority of a window of three
+	if s := m.Add(Reading{Scale: "bench", Grams: 900}); s.Grams != 900 || s.Stable {
+		t.Errorf("after a new load: %+v, want an unstable 900 g", s)
+	}
+}
+
+func TestNeedsFullWindow(t *testing.T) {
+	m := newMonitor(Options{})
+	m.Add(Reading{Scale: "bench", Grams: 500})
+	time.Sleep(settle + 10*time.Millisecond)
+	if s := m.Add(Reading{Scale: "bench", Grams: 500}); s.Stable {
+		t.Error("stable with two readings in a window of three")
+	}
+	if s := m.Add(Reading{Scale: "bench", Grams: 500}); !s.Stable {
+		t.Error("not stable with a full window that settled")
+	}
+}
+
+func TestStability(t *testing.T) {
+	var states []State
+	m := newMonitor(Options{Tolerance: 2, Settle: 50 * time.Millisecond, OnState: func(s State) {
+		states = append(states, s)
+	}})
+
+	for _, g := range []float64{500, 501, 499} {
+		if s := m.Add(Reading{Scale: "bench", Grams: g}); s.Stable {
+			t.Fatalf("stable right after %g g was put on", g)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	// Drifting within the tolerance keeps the load settled
+	if s := m.Add(Reading{Scale: "bench", Grams: 501.5}); !s.Stable || s.Grams != 501 {
+		t.Fatalf("after settling: %+v, want a stable 501 g", s)
+	}
+
+	// Moving past the tolerance starts settling over
+	m.Add(Reading{Scale: "bench", Grams: 510})
+	if s := m.Add(Reading{Scale: "bench", Grams: 510}); s.Stable {
+		t.Error("still stable after the weight moved 9 g")
+	}
+
+	if len(states) == 0 || states[0].Grams != 500 || states[0].Stable {
+		t.Fatalf("first state = %+v, want an unstable 500 g", states)
+	}
+	var sawStable bool
+	for _, s := range states {
+		sawStable = sawStable || s.Stable
+	}
+	if !sawStable || states[len(states)-1].Stable {
+		t.Errorf("states = %+v, want stable and then unstable again", states)
+	}
+}
+
+func TestScanThenLoad(t *testing.T) {
+	m := newMonitor(Options{})
+	m.Scanned(12)
+	if scan, ok := m.Pending(); !ok || scan.SpoolID != 12 {
+		t.Fatalf("pending scan = %+v, %t, want spool 12", scan, ok)
+	}
+
+	load(t, m, "bench", 812.4)
+	w, ok := weighing(m)
+	if !ok {
+		t.Fatal("no weighing after the scanned spool settled")
+	}
+	if w.SpoolID != 12 || w.Scale != "bench" || w.Grams != 812.4 {
+		t.Errorf("weighing = %+v, want spool 12 at 812.4 g on bench", w)
+	}
+	if _, ok := m.Pending(); ok {
+		t.Error("scan still pending after it was weighed")
+	}
+
+	// The load is reported once, not on every further reading
+	m.Add(Reading{Scale: "bench", Grams: 812.4})
+	if w, ok := weighing(m); ok {
+		t.Errorf("weighed again: %+v", w)
+	}
+}
+
+func TestScanWithSettledLoad(t *testing.T) {
+	m := newMonitor(Options{})
+	load(t, m, "bench", 640)
+	load(t, m, "shelf", 300)
+	if w, ok := weighing(m); ok {
+		t.Fatalf("weighed without a scan: %+v", w)
+	}
+
+	// The spool on the scale that settled last is the one just scanned
+	m.Scanned(7)
+	w, ok := weighing(m)
+	if !ok || w.SpoolID != 7 || w.Scale != "shelf" || w.Grams != 300 {
+		t.Fatalf("weighing = %+v, %t, want spool 7 at 300 g on shelf", w, ok)
+	}
+
+	m.Scanned(8)
+	w, ok = weighing(m)
+	if !ok || w.Scale != "bench" {
+		t.Fatalf("weighing = %+v, %t, want spool 8 on bench", w, ok)
+	}
+
+	// Both loads were recorded, a third spool waits for its own
+	m.Scanned(9)
+	if w, ok := weighing(m); ok {
+		t.Errorf("weighed spool 9 with a load already recorded: %+v", w)
+	}
+	if scan, ok := m.Pending(); !ok || scan.SpoolID != 9 {
+		t.Errorf("pending scan = %+v, %t, want spool 9", scan, ok)
+	}
+}
+
+func TestMinWeight(t *testing.T) {
+	m := newMonitor(Options{MinWeight: 20})
+	m.Scanned(12)
+	load(t, m, "bench", 4)
+	if w, ok := weighing(m); ok {
+		t.Errorf("weighed an empty scale: %+v", w)
+	}
+	if _, ok := m.Pending(); !ok {
+		t.Error("an empty scale used up the scan")
+	}
+
+	load(t, m, "bench", 20)
+	if w, ok := weighing(m); !ok || w.SpoolID != 12 {
+		t.Errorf("weighing = %+v, %t, want spool 12 at the minimum weight", w, ok)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	m := newMonitor(Options{ScanTimeout: 20 * time.Millisecond})
+	m.Scanned(12)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := m.Pending(); ok {
+		t.Error("scan still pending after the scan timeout")
+	}
+	load(t, m, "bench", 812)
+	if w, ok := weighing(m); ok {
+		t.Errorf("weighed a scan that timed out: %+v", w)
+	}
+
+	// A load that settled longer ago than the timeout is left alone too
+	time.Sleep(settle + 30*time.Millisecond)
+	m.Scanned(13)
+	if w, ok := weighing(m); ok {
+		t.Errorf("weighed a load that settled before the scan timeout: %+v", w)
+	}
+}
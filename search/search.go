@@ -0,0 +1,171 @@
+// Package search ranks free-text queries against weighted text fields and
+// splits matched text into segments for highlighting.
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed free-text search. Every term has to match somewhere.
+type Query struct {
+	// Terms are lower case, without duplicates.
+	Terms []string
+}
+
+// Parse splits s into terms at spaces and commas. Surrounding quotes and
+// punctuation are dropped, so `"galaxy black", petg` has three terms.
+func Parse(s string) Query {
+	var q Query
+	// Lower case rune by rune, as terms are matched rune by rune
+	fields := strings.FieldsFunc(strings.Map(unicode.ToLower, s), func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+	for _, f := range fields {
+		f = strings.TrimFunc(f, func(r rune) bool {
+			return unicode.IsPunct(r) && r != '#' && r != '+'
+		})
+		if f != "" && !slices.Contains(q.Terms, f) {
+			q.Terms = append(q.Terms, f)
+		}
+	}
+	return q
+}
+
+// Empty reports whether q has no terms and so matches everything.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0
+}
+
+// Field is text to search with how much a match in it counts.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// How much a term counts depending on where it matches in a word.
+const (
+	wholeWord  = 1.0
+	wordPrefix = 0.75
+	substring  = 0.4
+)
+
+// Score rates how well fields match q: every term counts for the field it
+// matches best, whole words more than word prefixes more than other
+// substrings. It is 0 when a term matches no field.
+func (q Query) Score(fields []Field) float64 {
+	total := 0.0
+	for _, term := range q.Terms {
+		best := 0.0
+		for _, f := range fields {
+			best = max(best, f.Weight*quality(f.Text, term))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// quality is how well term occurs in text, 0 if it does not.
+func quality(text, term string) float64 {
+	q := 0.0
+	for i := 0; i < len(text); {
+		if end := matchAt(text, i, term); end >= 0 {
+			switch {
+			case wordStart(text, i) && wordEnd(text, end):
+				return wholeWord
+			case wordStart(text, i):
+				q = max(q, wordPrefix)
+			default:
+				q = max(q, substring)
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return q
+}
+
+// matchAt returns where term ends if it occurs in text at byte offset i,
+// ignoring case, or -1.
+func matchAt(text string, i int, term string) int {
+	for _, tr := range term {
+		if i >= len(text) {
+			return -1
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.ToLower(r) != tr {
+			return -1
+		}
+		i += size
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordStart(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return i == 0 || !isWordRune(r)
+}
+
+func wordEnd(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return i == len(text) || !isWordRune(r)
+}
+
+// Segment is a piece of highlighted text.
+type Segment struct {
+	Text  string
+	Match bool
+}
+
+// Highlight splits text into segments, marking every occurrence of any of
+// terms. Without matches it returns text as a single segment.
+func Highlight(text string, terms []string) []Segment {
+	var segs []Segment
+	add := func(s string, match bool) {
+		if s == "" {
+			return
+		}
+		if n := len(segs); n > 0 && segs[n-1].Match == match {
+			segs[n-1].Text += s
+			return
+		}
+		segs = append(segs, Segment{Text: s, Match: match})
+	}
+
+	plain := 0
+	for i := 0; i < len(text); {
+		end := -1
+		for _, term := range terms {
+			end = max(end, matchAt(text, i, term))
+		}
+		if end > i {
+			add(text[plain:i], false)
+			add(text[i:end], true)
+			i, plain = end, end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	add(text[plain:], false)
+	return segs
+}
+
+// Matches reports whether any of terms occurs in text.
+func Matches(text string, terms []string) bool {
+	for _, term := range terms {
+		if quality(text, term) > 0 {
+			return true
+		}
+	}
+	return false
+}
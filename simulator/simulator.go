@@ -0,0 +1,131 @@
+// Package simulator is an in-process LED controller. It speaks the same
+// /led protocol as the chamber firmware and keeps the latest frame of every
+// pin, so LED features can be developed without the hardware.
+package simulator
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tryy3/filament-chamber/layout"
+)
+
+// Frame is the last set of colors received for one pin.
+type Frame struct {
+	Controller string    `json:"controller"`
+	Pin        int       `json:"pin"`
+	Colors     [][]int   `json:"colors"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Simulator stores frames per controller and pin.
+type Simulator struct {
+	mu     sync.Mutex
+	frames map[string]map[int]*Frame
+}
+
+func New() *Simulator {
+	return &Simulator{
+		frames: map[string]map[int]*Frame{},
+	}
+}
+
+type ledRequest struct {
+	Pin    *int    `json:"pin"`
+	Colors [][]int `json:"colors"`
+}
+
+// LEDHandler accepts frames for the controller named in the path
+// (POST /simulator/{controller}/led), exactly like the firmware does.
+func (s *Simulator) LEDHandler(w http.ResponseWriter, r *http.Request) {
+	controller := r.PathValue("controller")
+
+	var req ledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Pin == nil {
+		http.Error(w, "Missing pin", http.StatusBadRequest)
+		return
+	}
+	for _, color := range req.Colors {
+		if len(color) != 3 {
+			http.Error(w, "Colors must be [r, g, b]", http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if s.frames[controller] == nil {
+		s.frames[controller] = map[int]*Frame{}
+	}
+	s.frames[controller][*req.Pin] = &Frame{
+		Controller: controller,
+		Pin:        *req.Pin,
+		Colors:     req.Colors,
+		Updated:    time.Now(),
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.Printf("Error writing simulator response: %v", err)
+	}
+}
+
+// Frames returns a copy of the latest frames, ordered by controller and pin.
+func (s *Simulator) Frames() []Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frames := []Frame{}
+	for _, pins := range s.frames {
+		for _, frame := range pins {
+			f := *frame
+			f.Colors = make([][]int, len(frame.Colors))
+			for i, color := range frame.Colors {
+				f.Colors[i] = slices.Clone(color)
+			}
+			frames = append(frames, f)
+		}
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		if frames[i].Controller != frames[j].Controller {
+			return frames[i].Controller < frames[j].Controller
+		}
+		return frames[i].Pin < frames[j].Pin
+	})
+	return frames
+}
+
+// Colors maps the received frames back to slot LEDs using the strip wiring,
+// keyed by LED name. Each slot LED is followed (or, with EmptyBefore,
+// preceded) by an unused LED on the strip.
+func (s *Simulator) Colors(strips []layout.Strip) map[string][]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	colors := map[string][]int{}
+	for _, strip := range strips {
+		frame, ok := s.frames[strip.Controller][strip.Pin]
+		if !ok {
+			continue
+		}
+		for i, led := range strip.LEDs {
+			index := 2 * i
+			if strip.EmptyBefore {
+				index++
+			}
+			if index < len(frame.Colors) {
+				colors[led] = slices.Clone(frame.Colors[index])
+			}
+		}
+	}
+	return colors
+}
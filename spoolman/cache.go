@@ -0,0 +1,140 @@
+package spoolman
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is used when the cache TTL is zero.
+const DefaultCacheTTL = 30 * time.Second
+
+// retryFailedRefresh is how long stale data is served without asking
+// Spoolman again after a refresh failed, so pages don't each wait for the
+// timeout while Spoolman is down.
+const retryFailedRefresh = 10 * time.Second
+
+// Snapshot is the spool list as of Fetched, archived spools included. The
+// spools are shared between callers and must not be modified.
+type Snapshot struct {
+	Spools  []Spool
+	Fetched time.Time
+	// Stale is set when the list could not be refreshed and older data is
+	// served instead. Err says why.
+	Stale bool
+	Err   error
+}
+
+// refresh is one FindSpools call shared by every caller waiting for it.
+type refresh struct {
+	done   chan struct{}
+	spools []Spool
+	err    error
+}
+
+// Cache keeps the spool list in memory for a while, so pages that need it
+// several times only ask Spoolman once. Concurrent misses share a single
+// request. When Spoolman cannot be reached, the last list is served as
+// stale rather than failing.
+type Cache struct {
+	service *Service
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	spools  []Spool
+	fetched time.Time
+	valid   bool
+	// generation counts invalidations, so a refresh that started before an
+	// invalidation does not count as fresh.
+	generation uint64
+	inflight   *refresh
+	// lastErr is the error of the last refresh if it failed at failed.
+	lastErr error
+	failed  time.Time
+}
+
+// NewCache creates a cache over service keeping the list for ttl.
+func NewCache(service *Service, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Cache{service: service, ttl: ttl}
+}
+
+// Invalidate makes the next Spools call fetch the list again. Call it after
+// changing spools or learning that they changed.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.valid = false
+	c.generation++
+	c.failed = time.Time{}
+}
+
+// Spools returns the cached list, refreshing it first if it expired or was
+// invalidated. It only fails when Spoolman is unreachable and nothing was
+// cached yet.
+func (c *Cache) Spools(ctx context.Context) (Snapshot, error) {
+	c.mu.Lock()
+	if c.valid && time.Since(c.fetched) < c.ttl {
+		snap := Snapshot{Spools: c.spools, Fetched: c.fetched}
+		c.mu.Unlock()
+		return snap, nil
+	}
+	if c.spools != nil && c.lastErr != nil && time.Since(c.failed) < retryFailedRefresh {
+		snap := Snapshot{Spools: c.spools, Fetched: c.fetched, Stale: true, Err: c.lastErr}
+		c.mu.Unlock()
+		return snap, nil
+	}
+	call := c.inflight
+	if call == nil {
+		call = &refresh{done: make(chan struct{})}
+		c.inflight = call
+		go c.refresh(ctx, call, c.generation)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return Snapshot{}, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if call.err == nil {
+		return Snapshot{Spools: call.spools, Fetched: c.fetched}, nil
+	}
+	if c.spools == nil {
+		return Snapshot{}, call.err
+	}
+	return Snapshot{Spools: c.spools, Fetched: c.fetched, Stale: true, Err: call.err}, nil
+}
+
+// refresh fetches the list for call. It is not cancelled with the caller
+// that started it, as other callers may be waiting too; the client timeout
+// bounds it.
+func (c *Cache) refresh(ctx context.Context, call *refresh, generation uint64) {
+	spools, err := c.service.FindSpools(context.WithoutCancel(ctx))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight = nil
+	if err != nil {
+		slog.Warn("refreshing spool cache", "error", err)
+		call.err = err
+		c.lastErr = err
+		c.failed = time.Now()
+	} else {
+		c.lastErr = nil
+		call.spools = []Spool{}
+		if spools != nil {
+			call.spools = *spools
+		}
+		c.spools = call.spools
+		c.fetched = time.Now()
+		c.valid = c.generation == generation
+	}
+	close(call.done)
+}
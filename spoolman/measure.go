@@ -0,0 +1,97 @@
+package spoolman
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// Weighing is what weighing a spool tells about it: the remaining filament
+// before and, once the measurement is recorded, after. Spoolman subtracts
+// the tare, the weight of the empty spool, from the gross weight.
+type Weighing struct {
+	Gross float32 `json:"gross"`
+	Tare  float32 `json:"tare"`
+	// Initial is the net filament weight of a full spool, 0 if unknown.
+	Initial float32 `json:"initial"`
+	Before  float32 `json:"before"`
+	After   float32 `json:"after"`
+	// Warnings explain why the measurement looks wrong, e.g. the wrong
+	// spool on the scale.
+	Warnings []string `json:"warnings,omitempty"`
+	// Notices are worth knowing about the measurement but do not make it
+	// look wrong, e.g. a missing empty spool weight.
+	Notices []string `json:"notices,omitempty"`
+}
+
+// SpoolTare is the weight of the empty spool: the spool's own spool weight
+// or, if it has none, its filament's. It is 0 if neither is known.
+func SpoolTare(s Spool) float32 {
+	if s.SpoolWeight != nil {
+		return GetSpoolSpoolWeight(s)
+	}
+	return GetFilamentSpoolWeight(s.Filament)
+}
+
+// SpoolInitial is the filament weight of the full spool, falling back to
+// the filament's weight as Spoolman does.
+func SpoolInitial(s Spool) float32 {
+	if s.InitialWeight != nil {
+		return GetSpoolInitialWeight(s)
+	}
+	return GetFilamentWeight(s.Filament)
+}
+
+// Weigh works out what recording gross as the weight of s would do,
+// without recording it. After is the remaining weight Spoolman will
+// compute, which never goes below 0 or above the initial weight.
+func Weigh(s Spool, gross float32) Weighing {
+	w := Weighing{
+		Gross:   gross,
+		Tare:    SpoolTare(s),
+		Initial: SpoolInitial(s),
+		Before:  GetSpoolRemainingWeight(s),
+	}
+	w.After = max(gross-w.Tare, 0)
+	if w.Initial > 0 {
+		w.After = min(w.After, w.Initial)
+	}
+
+	if w.Tare == 0 {
+		w.Notices = append(w.Notices, "The empty spool weight is not set, the whole measurement counts as filament")
+	}
+	if gross < w.Tare {
+		w.Warnings = append(w.Warnings, fmt.Sprintf("%.0fg is less than the empty spool (%.0fg)", gross, w.Tare))
+	}
+	if w.Initial > 0 && gross > w.Initial+w.Tare {
+		w.Warnings = append(w.Warnings, fmt.Sprintf("%.0fg is more than the full spool (%.0fg)", gross, w.Initial+w.Tare))
+	}
+	return w
+}
+
+// ValidateGross checks that a measured gross weight is a positive number.
+func ValidateGross(gross float32) error {
+	if gross <= 0 || math.IsNaN(float64(gross)) || math.IsInf(float64(gross), 0) {
+		return &FieldError{Field: "weight", Message: "must be a positive number"}
+	}
+	return nil
+}
+
+// MeasureSpool records gross as the current weight of a spool, letting
+// Spoolman work out how much filament was used, and returns the updated
+// spool.
+func (s *Service) MeasureSpool(ctx context.Context, spoolID int, gross float32) (*Spool, error) {
+	if err := ValidateGross(gross); err != nil {
+		return nil, err
+	}
+
+	rsp, err := s.client.UseSpoolFilamentBasedOnTheCurrentWeightMeasurementSpoolSpoolIdMeasurePutWithResponse(ctx, spoolID, SpoolMeasureParameters{Weight: gross})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() == http.StatusOK && rsp.JSON200 != nil {
+		return rsp.JSON200, nil
+	}
+	return nil, writeError(spoolID, rsp.StatusCode(), rsp.JSON400, rsp.JSON422)
+}
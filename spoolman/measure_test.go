@@ -0,0 +1,46 @@
+package spoolman
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func spoolJSON(t *testing.T, s string) Spool {
+	t.Helper()
+	var spool Spool
+	if err := json.Unmarshal([]byte(s), &spool); err != nil {
+		t.Fatalf("decoding spool: %v", err)
+	}
+	return spool
+}
+
+func TestWeigh(t *testing.T) {
+	spool := spoolJSON(t, `{"id": 12, "registered": "2024-01-01T00:00:00", "archived": false, "extra": {}, "remaining_weight": 600, "initial_weight": 1000, "spool_weight": 200, "filament": {"id": 3, "registered": "2024-01-01T00:00:00", "density": 1.24, "diameter": 1.75, "extra": {}}}`)
+	for _, tc := range []struct {
+		gross    float32
+		after    float32
+		warnings int
+	}{
+		{700, 500, 0},
+		{150, 0, 1},
+		{1300, 1000, 1},
+	} {
+		w := Weigh(spool, tc.gross)
+		if w.Tare != 200 || w.Initial != 1000 || w.Before != 600 || w.After != tc.after {
+			t.Errorf("Weigh(%g) = %+v, want 200 g tare and %g g after", tc.gross, w, tc.after)
+		}
+		if len(w.Warnings) != tc.warnings || len(w.Notices) != 0 {
+			t.Errorf("Weigh(%g) warnings = %q, notices = %q, want %d warnings", tc.gross, w.Warnings, w.Notices, tc.warnings)
+		}
+	}
+}
+
+func TestWeighWithoutTare(t *testing.T) {
+	spool := spoolJSON(t, `{"id": 12, "registered": "2024-01-01T00:00:00", "archived": false, "extra": {}, "filament": {"id": 3, "registered": "2024-01-01T00:00:00", "density": 1.24, "diameter": 1.75, "extra": {}}}`)
+	// A missing empty spool weight is worth knowing, but not a reason to
+	// doubt the measurement.
+	w := Weigh(spool, 700)
+	if len(w.Warnings) != 0 || len(w.Notices) != 1 || w.After != 700 {
+		t.Errorf("Weigh(700) without a tare = %+v, want one notice and 700 g after", w)
+	}
+}
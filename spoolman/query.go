@@ -0,0 +1,110 @@
+package spoolman
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Fields spools can be sorted by, see SpoolQuery.Sort.
+const (
+	SortID              = "id"
+	SortFilamentName    = "filament.name"
+	SortMaterial        = "filament.material"
+	SortVendor          = "filament.vendor.name"
+	SortLocation        = "location"
+	SortRemainingWeight = "remaining_weight"
+	SortRegistered      = "registered"
+)
+
+// SpoolQuery selects a page of spools. The text filters are search terms as
+// Spoolman understands them: separated by commas, matched partially and
+// case-insensitively unless quoted (see Exact). Empty filters match
+// everything.
+type SpoolQuery struct {
+	Material string
+	Vendor   string
+	Location string
+	LotNr    string
+	// Archived includes archived spools.
+	Archived bool
+	// Sort is a comma-separated list of "field:asc" or "field:desc" items.
+	Sort string
+	// Limit is the page size, 0 for all spools. Offset skips that many
+	// matching spools.
+	Limit  int
+	Offset int
+}
+
+// SpoolPage is one page of a spool query.
+type SpoolPage struct {
+	Spools []Spool
+	// Total is the number of spools matching the query across all pages.
+	Total int
+}
+
+// Exact quotes term so Spoolman matches it exactly rather than partially.
+func Exact(term string) string {
+	return `"` + term + `"`
+}
+
+// QuerySpools returns the spools matching q, filtered, sorted and paged by
+// Spoolman.
+func (s *Service) QuerySpools(ctx context.Context, q SpoolQuery) (*SpoolPage, error) {
+	params := &FindSpoolSpoolGetParams{}
+	if q.Archived {
+		params.AllowArchived = &q.Archived
+	}
+	if q.Offset > 0 {
+		params.Offset = &q.Offset
+	}
+	terms := map[string]string{
+		"filament.material":    q.Material,
+		"filament.vendor.name": q.Vendor,
+		"location":             q.Location,
+		"lot_nr":               q.LotNr,
+		"sort":                 q.Sort,
+	}
+	if q.Limit > 0 {
+		terms["limit"] = strconv.Itoa(q.Limit)
+	}
+
+	rsp, err := s.client.FindSpoolSpoolGetWithResponse(ctx, params, addQuery(terms))
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() != http.StatusOK || rsp.JSON200 == nil {
+		log.Printf("Expected HTTP 200 but received %d", rsp.StatusCode())
+		return nil, fmt.Errorf("expected HTTP 200 but received %d", rsp.StatusCode())
+	}
+	page := &SpoolPage{Spools: *rsp.JSON200, Total: q.Offset + len(*rsp.JSON200)}
+	if total, err := strconv.Atoi(rsp.HTTPResponse.Header.Get("X-Total-Count")); err == nil {
+		page.Total = total
+	}
+	return page, nil
+}
+
+// MatchesSearch reports whether value matches search the way Spoolman
+// matches a text filter, for filtering spools locally. An empty search
+// matches everything.
+func MatchesSearch(value, search string) bool {
+	if strings.TrimSpace(search) == "" {
+		return true
+	}
+	for _, term := range strings.Split(search, ",") {
+		term = strings.TrimSpace(term)
+		if len(term) >= 2 && strings.HasPrefix(term, `"`) && strings.HasSuffix(term, `"`) {
+			if strings.EqualFold(value, term[1:len(term)-1]) {
+				return true
+			}
+			continue
+		}
+		if term != "" && strings.Contains(strings.ToLower(value), strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package spoolman
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tryy3/filament-chamber/colors"
+)
+
+// Longest names Spoolman accepts for vendors and filaments.
+const (
+	MaxVendorNameLength   = 64
+	MaxFilamentNameLength = 64
+	MaxMaterialLength     = 64
+)
+
+// FilamentSpec describes a filament read from a spool tag, e.g. the main
+// section of an OpenPrintTag.
+type FilamentSpec struct {
+	Vendor string
+	// Name is the filament name; it defaults to the names of its colors.
+	Name     string
+	Material string
+	// ColorHex is the primary color as "RRGGBB" or "RRGGBBAA". A
+	// multi-color filament also lists its other colors in MultiColorHexes,
+	// laid out along MultiColorDirection.
+	ColorHex            string
+	MultiColorHexes     []string
+	MultiColorDirection string
+	// Density is in g/cm³ and Diameter in mm.
+	Density  float32
+	Diameter float32
+	// Weight is the net weight of a full spool and SpoolWeight that of the
+	// empty spool, in grams. Zero leaves them unset.
+	Weight      float32
+	SpoolWeight float32
+	// ExtruderTemp and BedTemp are in °C. Zero leaves them unset.
+	ExtruderTemp int
+	BedTemp      int
+}
+
+// normalize trims the text fields, drops '#' from the colors and names the
+// filament after its colors, or its material, if it has no name.
+func (f FilamentSpec) normalize() FilamentSpec {
+	f.Vendor = strings.TrimSpace(f.Vendor)
+	f.Name = strings.TrimSpace(f.Name)
+	f.Material = strings.TrimSpace(f.Material)
+	hex := func(v string) string {
+		return strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(v), "#"))
+	}
+	f.ColorHex = hex(f.ColorHex)
+	others := make([]string, 0, len(f.MultiColorHexes))
+	for _, c := range f.MultiColorHexes {
+		if c = hex(c); c != "" {
+			others = append(others, c)
+		}
+	}
+	f.MultiColorHexes = others
+	if f.Name == "" {
+		cs := colors.ParseHexList(strings.Join(f.hexes(), ","))
+		f.Name = colors.Describe(cs, f.MultiColorDirection)
+	}
+	if f.Name == "" {
+		f.Name = f.Material
+	}
+	return f
+}
+
+// hexes returns every color of the filament, the primary one first.
+func (f FilamentSpec) hexes() []string {
+	if f.ColorHex == "" {
+		return f.MultiColorHexes
+	}
+	return append([]string{f.ColorHex}, f.MultiColorHexes...)
+}
+
+// Validate checks the filament against what Spoolman requires.
+func (f FilamentSpec) Validate() error {
+	f = f.normalize()
+	var errs []error
+	checkText := func(field, v string, limit int) {
+		switch {
+		case v == "":
+			errs = append(errs, &FieldError{Field: field, Message: "is required"})
+		case utf8.RuneCountInString(v) > limit:
+			errs = append(errs, &FieldError{Field: field, Message: "must be at most " + strconv.Itoa(limit) + " characters"})
+		}
+	}
+	checkText("vendor", f.Vendor, MaxVendorNameLength)
+	checkText("name", f.Name, MaxFilamentNameLength)
+	checkText("material", f.Material, MaxMaterialLength)
+	for _, hex := range f.hexes() {
+		if _, err := colors.ParseHex(hex); err != nil || (len(hex) != 6 && len(hex) != 8) {
+			errs = append(errs, &FieldError{Field: "color_hex", Message: "must be a hex color, not " + strconv.Quote(hex)})
+		}
+	}
+	if len(f.MultiColorHexes) > 0 && f.MultiColorDirection != colors.Coaxial && f.MultiColorDirection != colors.Longitudinal {
+		errs = append(errs, &FieldError{Field: "multi_color_direction", Message: "must be coaxial or longitudinal"})
+	}
+	checkAmount := func(field string, v float32, required bool) {
+		switch {
+		case math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) || v < 0:
+			errs = append(errs, &FieldError{Field: field, Message: "must be a positive number"})
+		case v == 0 && required:
+			errs = append(errs, &FieldError{Field: field, Message: "is required"})
+		}
+	}
+	checkAmount("density", f.Density, true)
+	checkAmount("diameter", f.Diameter, true)
+	checkAmount("weight", f.Weight, false)
+	checkAmount("spool_weight", f.SpoolWeight, false)
+	if f.ExtruderTemp < 0 {
+		errs = append(errs, &FieldError{Field: "settings_extruder_temp", Message: "must be a positive number"})
+	}
+	if f.BedTemp < 0 {
+		errs = append(errs, &FieldError{Field: "settings_bed_temp", Message: "must be a positive number"})
+	}
+	return errors.Join(errs...)
+}
+
+// matches reports whether a filament in Spoolman is the one described:
+// same material, colors and diameter, where no color matches no color. The
+// vendor and name are matched by the query.
+func (f FilamentSpec) matches(fil Filament) bool {
+	if !strings.EqualFold(GetFilamentName(fil), f.Name) || !strings.EqualFold(GetFilamentMaterial(fil), f.Material) {
+		return false
+	}
+	if math.Abs(float64(fil.Diameter-f.Diameter)) > 0.01 {
+		return false
+	}
+	if len(f.MultiColorHexes) > 0 {
+		return strings.EqualFold(GetFilamentMultiColorHexes(fil), strings.Join(f.hexes(), ","))
+	}
+	if f.ColorHex == "" {
+		// A tag without a color is the filament that has none either
+		return fil.ColorHex == nil && GetFilamentMultiColorHexes(fil) == ""
+	}
+	return fil.ColorHex != nil && strings.EqualFold(GetFilamentColorHex(fil), f.ColorHex)
+}
+
+// NewSpool is a spool to add to Spoolman, along with its filament.
+type NewSpool struct {
+	Filament FilamentSpec
+	// InitialWeight is the net weight of this spool when full, in grams, if
+	// it differs from the filament's.
+	InitialWeight float32
+}
+
+// Validate checks the spool and its filament.
+func (n NewSpool) Validate() error {
+	var err error
+	if v := n.InitialWeight; math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) || v < 0 {
+		err = &FieldError{Field: "initial_weight", Message: "must be a positive number"}
+	}
+	return errors.Join(n.Filament.Validate(), err)
+}
+
+// Registration is a spool added by RegisterSpool. VendorCreated and
+// FilamentCreated are set if they did not exist in Spoolman yet.
+type Registration struct {
+	Spool           *Spool
+	VendorCreated   bool
+	FilamentCreated bool
+}
+
+// RegisterSpool adds a spool to Spoolman, first adding its vendor and
+// filament unless Spoolman already has them.
+func (s *Service) RegisterSpool(ctx context.Context, n NewSpool) (*Registration, error) {
+	if err := n.Validate(); err != nil {
+		return nil, err
+	}
+	n.Filament = n.Filament.normalize()
+
+	vendor, vendorCreated, err := s.FindOrCreateVendor(ctx, n.Filament.Vendor)
+	if err != nil {
+		return nil, err
+	}
+	filament, filamentCreated, err := s.FindOrCreateFilament(ctx, vendor.Id, n.Filament)
+	if err != nil {
+		return nil, err
+	}
+
+	params := SpoolParameters{FilamentId: filament.Id}
+	if n.InitialWeight > 0 {
+		var weight SpoolParameters_InitialWeight
+		if err := weight.FromSpoolParametersInitialWeight0(n.InitialWeight); err != nil {
+			return nil, err
+		}
+		params.InitialWeight = &weight
+	}
+	rsp, err := s.client.AddSpoolSpoolPostWithResponse(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() != http.StatusOK || rsp.JSON200 == nil {
+		return nil, responseError(rsp.StatusCode(), rsp.JSON400, rsp.JSON422)
+	}
+	return &Registration{Spool: rsp.JSON200, VendorCreated: vendorCreated, FilamentCreated: filamentCreated}, nil
+}
+
+// FindOrCreateVendor returns the vendor with the given name, ignoring case,
+// adding it to Spoolman if there is none. created is set if it was added.
+func (s *Service) FindOrCreateVendor(ctx context.Context, name string) (vendor *Vendor, created bool, err error) {
+	name = strings.TrimSpace(name)
+	rsp, err := s.client.FindVendorVendorGetWithResponse(ctx, &FindVendorVendorGetParams{}, addQuery(map[string]string{
+		"name": Exact(name),
+	}))
+	if err != nil {
+		return nil, false, err
+	}
+	if rsp.StatusCode() != http.StatusOK || rsp.JSON200 == nil {
+		return nil, false, responseError(rsp.StatusCode(), nil, rsp.JSON422)
+	}
+	for _, v := range *rsp.JSON200 {
+		if strings.EqualFold(v.Name, name) {
+			return &v, false, nil
+		}
+	}
+
+	add, err := s.client.AddVendorVendorPostWithResponse(ctx, VendorParameters{Name: name})
+	if err != nil {
+		return nil, false, err
+	}
+	if add.StatusCode() != http.StatusOK || add.JSON200 == nil {
+		return nil, false, responseError(add.StatusCode(), add.JSON400, add.JSON422)
+	}
+	return add.JSON200, true, nil
+}
+
+// FindOrCreateFilament returns the vendor's filament with the name,
+// material, colors and diameter of f, adding it to Spoolman if there is
+// none. created is set if it was added.
+func (s *Service) FindOrCreateFilament(ctx context.Context, vendorID int, f FilamentSpec) (filament *Filament, created bool, err error) {
+	f = f.normalize()
+	rsp, err := s.client.FindFilamentsFilamentGetWithResponse(ctx, &FindFilamentsFilamentGetParams{}, addQuery(map[string]string{
+		"vendor.id": strconv.Itoa(vendorID),
+		"name":      Exact(f.Name),
+		"material":  Exact(f.Material),
+	}))
+	if err != nil {
+		return nil, false, err
+	}
+	if rsp.StatusCode() != http.StatusOK || rsp.JSON200 == nil {
+		return nil, false, responseError(rsp.StatusCode(), nil, rsp.JSON422)
+	}
+	for _, fil := range *rsp.JSON200 {
+		if f.matches(fil) {
+			return &fil, false, nil
+		}
+	}
+
+	params, err := f.parameters(vendorID)
+	if err != nil {
+		return nil, false, err
+	}
+	add, err := s.client.AddFilamentFilamentPostWithResponse(ctx, params)
+	if err != nil {
+		return nil, false, err
+	}
+	if add.StatusCode() != http.StatusOK || add.JSON200 == nil {
+		return nil, false, responseError(add.StatusCode(), add.JSON400, add.JSON422)
+	}
+	return add.JSON200, true, nil
+}
+
+// parameters builds the Spoolman filament for f.
+func (f FilamentSpec) parameters(vendorID int) (FilamentParameters, error) {
+	params := FilamentParameters{Density: f.Density, Diameter: f.Diameter}
+	params.VendorId = &FilamentParameters_VendorId{}
+	params.Name = &FilamentParameters_Name{}
+	params.Material = &FilamentParameters_Material{}
+	errs := []error{
+		params.VendorId.FromFilamentParametersVendorId0(vendorID),
+		params.Name.FromFilamentParametersName0(f.Name),
+		params.Material.FromFilamentParametersMaterial0(f.Material),
+	}
+	if len(f.MultiColorHexes) > 0 {
+		params.MultiColorHexes = &FilamentParameters_MultiColorHexes{}
+		params.MultiColorDirection = &FilamentParameters_MultiColorDirection{}
+		errs = append(errs,
+			params.MultiColorHexes.FromFilamentParametersMultiColorHexes0(strings.Join(f.hexes(), ",")),
+			params.MultiColorDirection.FromMultiColorDirectionInput(MultiColorDirectionInput(f.MultiColorDirection)))
+	} else if f.ColorHex != "" {
+		params.ColorHex = &FilamentParameters_ColorHex{}
+		errs = append(errs, params.ColorHex.FromFilamentParametersColorHex0(f.ColorHex))
+	}
+	if f.Weight > 0 {
+		params.Weight = &FilamentParameters_Weight{}
+		errs = append(errs, params.Weight.FromFilamentParametersWeight0(f.Weight))
+	}
+	if f.SpoolWeight > 0 {
+		params.SpoolWeight = &FilamentParameters_SpoolWeight{}
+		errs = append(errs, params.SpoolWeight.FromFilamentParametersSpoolWeight0(f.SpoolWeight))
+	}
+	if f.ExtruderTemp > 0 {
+		params.SettingsExtruderTemp = &FilamentParameters_SettingsExtruderTemp{}
+		errs = append(errs, params.SettingsExtruderTemp.FromFilamentParametersSettingsExtruderTemp0(f.ExtruderTemp))
+	}
+	if f.BedTemp > 0 {
+		params.SettingsBedTemp = &FilamentParameters_SettingsBedTemp{}
+		errs = append(errs, params.SettingsBedTemp.FromFilamentParametersSettingsBedTemp0(f.BedTemp))
+	}
+	return params, errors.Join(errs...)
+}
+
+// addQuery adds query terms to a request. The string and integer filters
+// are unions in the generated params, which the generated client cannot
+// encode, so they are added to the query directly.
+func addQuery(terms map[string]string) RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		query := req.URL.Query()
+		for k, v := range terms {
+			if v != "" {
+				query.Set(k, v)
+			}
+		}
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package spoolman
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SpoolID is a spool ID in a JSON message, sent either as a number or as a
+// numeric string. The browser and the transfer consumers send strings, so it
+// is written back as one.
+type SpoolID int
+
+func (id *SpoolID) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = strings.TrimSpace(unquoted)
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return fmt.Errorf("spoolId %s is not a number", string(data))
+	}
+	*id = SpoolID(n)
+	return nil
+}
+
+func (id SpoolID) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.Itoa(int(id)))), nil
+}
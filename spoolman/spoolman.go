@@ -2,28 +2,145 @@ package spoolman
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/tryy3/filament-chamber/colors"
 )
 
-var apiClient *ClientWithResponses
+// DefaultTimeout is used when Config.Timeout is left at zero.
+const DefaultTimeout = 10 * time.Second
 
-func init() {
-	hc := &http.Client{
-		Timeout: 10 * time.Second,
+// ErrNotFound is returned when the requested object does not exist in Spoolman.
+var ErrNotFound = errors.New("not found in spoolman")
+
+// Config describes how to reach a Spoolman instance.
+type Config struct {
+	// BaseURL is the API root, e.g. "https://spoolman.example.com/api/v1".
+	BaseURL string
+	// Timeout bounds every request made to Spoolman.
+	Timeout time.Duration
+	// Headers are added to every request (e.g. an Authorization header
+	// when Spoolman sits behind a reverse proxy).
+	Headers map[string]string
+	TLS     TLSConfig
+}
+
+// TLSConfig controls how the HTTPS connection to Spoolman is verified.
+type TLSConfig struct {
+	// CAFile is an optional PEM bundle used instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile enable client certificate authentication.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables certificate verification. Only use this
+	// against a local Spoolman with a self-signed certificate.
+	InsecureSkipVerify bool
+}
+
+// Service is a Spoolman API client bound to a single instance.
+type Service struct {
+	baseURL string
+	client  *ClientWithResponses
+	// headers and tlsConfig are reused for the change feed, see Subscriber.
+	headers   map[string]string
+	tlsConfig *tls.Config
+}
+
+// NewService builds a Service from cfg.
+func NewService(cfg Config) (*Service, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("spoolman: base URL is required")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
 	}
-	c, err := NewClientWithResponses("https://spoolman.tryy3.dev/api/v1", WithHTTPClient(hc))
+
+	tlsConfig, err := cfg.TLS.build()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	hc := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	opts := []ClientOption{WithHTTPClient(hc)}
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if len(headers) > 0 {
+		opts = append(opts, WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			return nil
+		}))
 	}
-	apiClient = c
+
+	c, err := NewClientWithResponses(cfg.BaseURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("spoolman: creating client: %w", err)
+	}
+	return &Service{
+		baseURL:   strings.TrimSuffix(cfg.BaseURL, "/"),
+		client:    c,
+		headers:   headers,
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+func (t TLSConfig) build() (*tls.Config, error) {
+	tc := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("spoolman: reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("spoolman: no certificates found in %s", t.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("spoolman: loading client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
 }
 
-func FindSpools() (*[]Spool, error) {
-	// params := &FindSpoolSpoolGetParams{}
-	rsp, err := apiClient.FindSpoolSpoolGetWithResponse(context.Background(), nil)
+// BaseURL returns the API root this service talks to.
+func (s *Service) BaseURL() string {
+	return s.baseURL
+}
+
+// Client exposes the generated client for endpoints without a helper.
+func (s *Service) Client() *ClientWithResponses {
+	return s.client
+}
+
+// FindSpools returns every spool, archived ones included.
+func (s *Service) FindSpools(ctx context.Context) (*[]Spool, error) {
+	allowArchived := true
+	params := &FindSpoolSpoolGetParams{AllowArchived: &allowArchived}
+	rsp, err := s.client.FindSpoolSpoolGetWithResponse(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -34,11 +151,14 @@ func FindSpools() (*[]Spool, error) {
 	return rsp.JSON200, nil
 }
 
-func GetSpool(spoolID int) (*Spool, error) {
-	rsp, err := apiClient.GetSpoolSpoolSpoolIdGetWithResponse(context.Background(), spoolID)
+func (s *Service) GetSpool(ctx context.Context, spoolID int) (*Spool, error) {
+	rsp, err := s.client.GetSpoolSpoolSpoolIdGetWithResponse(ctx, spoolID)
 	if err != nil {
 		return nil, err
 	}
+	if rsp.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("spool %d: %w", spoolID, ErrNotFound)
+	}
 	if rsp.StatusCode() != http.StatusOK {
 		log.Printf("Expected HTTP 200 but received %d", rsp.StatusCode())
 		return nil, fmt.Errorf("expected HTTP 200 but received %d", rsp.StatusCode())
@@ -46,6 +166,45 @@ func GetSpool(spoolID int) (*Spool, error) {
 	return rsp.JSON200, nil
 }
 
+// FindLocations returns every location Spoolman knows about.
+func (s *Service) FindLocations(ctx context.Context) ([]string, error) {
+	rsp, err := s.client.FindLocationsLocationGetWithResponse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() != http.StatusOK || rsp.JSON200 == nil {
+		log.Printf("Expected HTTP 200 but received %d", rsp.StatusCode())
+		return nil, fmt.Errorf("expected HTTP 200 but received %d", rsp.StatusCode())
+	}
+	return *rsp.JSON200, nil
+}
+
+// UpdateSpoolLocation moves a spool to location and returns the updated spool.
+func (s *Service) UpdateSpoolLocation(ctx context.Context, spoolID int, location string) (*Spool, error) {
+	var loc SpoolUpdateParameters_Location
+	if err := loc.FromSpoolUpdateParametersLocation0(location); err != nil {
+		return nil, err
+	}
+	rsp, err := s.client.UpdateSpoolSpoolSpoolIdPatchWithResponse(ctx, spoolID, SpoolUpdateParameters{
+		Location: &loc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch rsp.StatusCode() {
+	case http.StatusOK:
+		return rsp.JSON200, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("spool %d: %w", spoolID, ErrNotFound)
+	case http.StatusBadRequest:
+		if rsp.JSON400 != nil {
+			return nil, fmt.Errorf("spoolman rejected the update: %s", rsp.JSON400.Message)
+		}
+	}
+	log.Printf("Expected HTTP 200 but received %d", rsp.StatusCode())
+	return nil, fmt.Errorf("expected HTTP 200 but received %d", rsp.StatusCode())
+}
+
 func GetFilamentName(f Filament) string {
 	if f.Name == nil {
 		return "Unknown Filament"
@@ -115,6 +274,35 @@ func GetFilamentMultiColorHexes(f Filament) string {
 	return multiColorHexes
 }
 
+func GetFilamentMultiColorDirection(f Filament) string {
+	if f.MultiColorDirection == nil {
+		return ""
+	}
+	direction, err := f.MultiColorDirection.AsSpoolmanApiV1ModelsMultiColorDirection()
+	if err != nil {
+		log.Printf("Error getting multi color direction: %+v", err)
+		return ""
+	}
+	return string(direction)
+}
+
+// GetFilamentColorName names the filament's color, or its colors for
+// multi-color filaments, e.g. "Teal" or "Red → Gold gradient". It is empty
+// when the filament has no color.
+func GetFilamentColorName(f Filament) string {
+	if hexes := GetFilamentMultiColorHexes(f); hexes != "" {
+		return colors.Describe(colors.ParseHexList(hexes), GetFilamentMultiColorDirection(f))
+	}
+	if f.ColorHex == nil {
+		return ""
+	}
+	c, err := colors.ParseHex(GetFilamentColorHex(f))
+	if err != nil {
+		return ""
+	}
+	return colors.Name(c)
+}
+
 func GetFilamentWeight(f Filament) float32 {
 	if f.Weight == nil {
 		return 0
@@ -187,6 +375,42 @@ func GetSpoolLotNr(s Spool) string {
 	return lot
 }
 
+func GetSpoolComment(s Spool) string {
+	if s.Comment == nil {
+		return ""
+	}
+	comment, err := s.Comment.AsSpoolComment0()
+	if err != nil {
+		log.Printf("Error getting spool comment: %+v", err)
+		return ""
+	}
+	return comment
+}
+
+func GetSpoolPrice(s Spool) *float32 {
+	if s.Price == nil {
+		return nil
+	}
+	price, err := s.Price.AsSpoolPrice0()
+	if err != nil {
+		log.Printf("Error getting spool price: %+v", err)
+		return nil
+	}
+	return &price
+}
+
+func GetFilamentComment(f Filament) string {
+	if f.Comment == nil {
+		return ""
+	}
+	comment, err := f.Comment.AsFilamentComment0()
+	if err != nil {
+		log.Printf("Error getting filament comment: %+v", err)
+		return ""
+	}
+	return comment
+}
+
 func GetSpoolLocation(s Spool) string {
 	if s.Location == nil {
 		return "Not specified"
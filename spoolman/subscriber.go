@@ -0,0 +1,257 @@
+package spoolman
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/tryy3/filament-chamber/backoff"
+)
+
+// Event is a change to a Spoolman entity: a SpoolEvent, FilamentEvent or
+// VendorEvent as generated from the API schema.
+type Event interface {
+	// Change is what happened to the entity: Added, Updated or Deleted.
+	Change() EventType
+	// Time is when the change happened, zero if Spoolman sent no valid date.
+	Time() time.Time
+}
+
+func (e SpoolEvent) Change() EventType    { return e.Type }
+func (e FilamentEvent) Change() EventType { return e.Type }
+func (e VendorEvent) Change() EventType   { return e.Type }
+
+func (e SpoolEvent) Time() time.Time    { return parseEventDate(e.Date) }
+func (e FilamentEvent) Time() time.Time { return parseEventDate(e.Date) }
+func (e VendorEvent) Time() time.Time   { return parseEventDate(e.Date) }
+
+// DecodeEvent decodes one change feed message. Changes to resources other
+// than spools, filaments and vendors return a nil Event.
+func DecodeEvent(data []byte) (Event, error) {
+	var head struct {
+		Type     EventType `json:"type"`
+		Resource string    `json:"resource"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	switch head.Type {
+	case Added, Updated, Deleted:
+	default:
+		return nil, fmt.Errorf("unknown change type %q", head.Type)
+	}
+
+	var event Event
+	var err error
+	switch head.Resource {
+	case "spool":
+		var e SpoolEvent
+		err = json.Unmarshal(data, &e)
+		event = e
+	case "filament":
+		var e FilamentEvent
+		err = json.Unmarshal(data, &e)
+		event = e
+	case "vendor":
+		var e VendorEvent
+		err = json.Unmarshal(data, &e)
+		event = e
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s event: %w", head.Resource, err)
+	}
+	return event, nil
+}
+
+// parseEventDate parses an event date, which Spoolman sends in UTC with or
+// without a time zone. Unparsable dates are returned as zero.
+func parseEventDate(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// A subscriber retries a lost change feed after a second at first and at
+// least once a minute.
+const (
+	DefaultMinReconnect = time.Second
+	DefaultMaxReconnect = time.Minute
+)
+
+// Keep-alive timing: a ping every pingInterval, and the connection is
+// considered dead without any message or pong for readTimeout.
+const (
+	pingInterval = 30 * time.Second
+	readTimeout  = 75 * time.Second
+)
+
+// SubscriberOptions set how a Subscriber reconnects and what it does once it
+// is connected. Reconnect delays that are not set use DefaultMinReconnect and
+// DefaultMaxReconnect.
+type SubscriberOptions struct {
+	// MinReconnect is the delay before the first reconnect, doubled per
+	// failed attempt up to MaxReconnect.
+	MinReconnect time.Duration
+	MaxReconnect time.Duration
+	// OnConnect is called every time the connection is (re)established.
+	// Changes made while disconnected are not replayed, so this is where
+	// cached state should be refreshed.
+	OnConnect func()
+}
+
+// Subscriber keeps a websocket connection to Spoolman's change feed open and
+// hands every spool, filament and vendor change to a handler.
+type Subscriber struct {
+	url string
+	// logURL is url without credentials.
+	logURL string
+	header http.Header
+	dialer *websocket.Dialer
+	opts   SubscriberOptions
+}
+
+// NewSubscriber creates a subscriber for the websocket at wsURL, e.g.
+// "wss://spoolman.example.com/api/v1/". tlsConfig may be nil.
+func NewSubscriber(wsURL string, header http.Header, tlsConfig *tls.Config, opts SubscriberOptions) *Subscriber {
+	if opts.MinReconnect <= 0 {
+		opts.MinReconnect = DefaultMinReconnect
+	}
+	if opts.MaxReconnect < opts.MinReconnect {
+		opts.MaxReconnect = max(DefaultMaxReconnect, opts.MinReconnect)
+	}
+	logURL := wsURL
+	if u, err := url.Parse(wsURL); err == nil {
+		logURL = u.Redacted()
+	}
+	return &Subscriber{
+		url:    wsURL,
+		logURL: logURL,
+		header: header,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: DefaultTimeout,
+			TLSClientConfig:  tlsConfig,
+		},
+		opts: opts,
+	}
+}
+
+// Subscriber returns a subscriber to the change feed of all entities of this
+// Spoolman instance, using the same headers and TLS settings as the API
+// client.
+func (s *Service) Subscriber(opts SubscriberOptions) (*Subscriber, error) {
+	u, err := url.Parse(s.baseURL + "/")
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("spoolman: cannot subscribe to %s", s.baseURL)
+	}
+	header := http.Header{}
+	for k, v := range s.headers {
+		header.Set(k, v)
+	}
+	return NewSubscriber(u.String(), header, s.tlsConfig, opts), nil
+}
+
+// Run connects and passes each change to handle until ctx is cancelled,
+// reconnecting with backoff whenever the connection fails. handle is called
+// from a single goroutine.
+func (s *Subscriber) Run(ctx context.Context, handle func(Event)) {
+	b := backoff.Backoff{Min: s.opts.MinReconnect, Max: s.opts.MaxReconnect}
+	b.Reconnect(ctx, func(connected func()) error {
+		return s.listen(ctx, handle, connected)
+	}, func(err error, delay time.Duration) {
+		slog.Warn("spoolman change feed disconnected, reconnecting", "url", s.logURL, "in", delay, "error", err)
+	})
+}
+
+// listen runs one connection until it fails. connected is called once the
+// connection is up.
+func (s *Subscriber) listen(ctx context.Context, handle func(Event), connected func()) error {
+	conn, resp, err := s.dialer.DialContext(ctx, s.url, s.header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("connecting: %w (HTTP %d)", err, resp.StatusCode)
+		}
+		return fmt.Errorf("connecting: %w", err)
+	}
+	defer conn.Close()
+	slog.Info("subscribed to spoolman change feed", "url", s.logURL)
+	connected()
+	if s.opts.OnConnect != nil {
+		s.opts.OnConnect()
+	}
+
+	// Close the connection to unblock the reader when ctx is cancelled, and
+	// ping so a dead connection is noticed.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				conn.Close()
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if kind != websocket.TextMessage {
+			continue
+		}
+		event, err := DecodeEvent(data)
+		if err != nil {
+			slog.Warn("skipping spoolman change event", "error", err, "message", truncate(string(data), 200))
+			continue
+		}
+		if event != nil {
+			handle(event)
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "…"
+}
@@ -0,0 +1,183 @@
+package spoolman
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	spoolUpdated    = `{"type": "updated", "resource": "spool", "date": "2024-05-01T12:30:00", "payload": {"id": 12, "registered": "2024-01-01T00:00:00", "archived": false, "extra": {}, "filament": {"id": 3, "registered": "2024-01-01T00:00:00", "density": 1.24, "diameter": 1.75, "extra": {}}}}`
+	filamentAdded   = `{"type": "added", "resource": "filament", "date": "2024-05-01T12:30:00Z", "payload": {"id": 3, "registered": "2024-01-01T00:00:00", "density": 1.24, "diameter": 1.75, "extra": {}}}`
+	vendorDeleted   = `{"type": "deleted", "resource": "vendor", "date": "not a date", "payload": {"id": 7, "registered": "2024-01-01T00:00:00", "name": "Prusament", "extra": {}}}`
+	settingsUpdated = `{"type": "updated", "resource": "setting", "date": "2024-05-01T12:30:00", "payload": {"key": "currency", "value": "\"EUR\""}}`
+)
+
+func TestDecodeEvent(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	event, err := DecodeEvent([]byte(spoolUpdated))
+	if err != nil {
+		t.Fatalf("spool event: %v", err)
+	}
+	spool, ok := event.(SpoolEvent)
+	if !ok {
+		t.Fatalf("spool event decoded as %T", event)
+	}
+	if spool.Payload.Id != 12 || spool.Payload.Filament.Id != 3 {
+		t.Errorf("spool event payload = spool %d, filament %d, want 12 and 3", spool.Payload.Id, spool.Payload.Filament.Id)
+	}
+	if spool.Change() != Updated || !spool.Time().Equal(when) {
+		t.Errorf("spool event = %s at %s, want updated at %s", spool.Change(), spool.Time(), when)
+	}
+
+	event, err = DecodeEvent([]byte(filamentAdded))
+	if err != nil {
+		t.Fatalf("filament event: %v", err)
+	}
+	filament, ok := event.(FilamentEvent)
+	if !ok {
+		t.Fatalf("filament event decoded as %T", event)
+	}
+	if filament.Payload.Id != 3 || filament.Change() != Added || !filament.Time().Equal(when) {
+		t.Errorf("filament event = %s of filament %d at %s, want added of 3 at %s", filament.Change(), filament.Payload.Id, filament.Time(), when)
+	}
+
+	event, err = DecodeEvent([]byte(vendorDeleted))
+	if err != nil {
+		t.Fatalf("vendor event: %v", err)
+	}
+	vendor, ok := event.(VendorEvent)
+	if !ok {
+		t.Fatalf("vendor event decoded as %T", event)
+	}
+	if vendor.Payload.Name != "Prusament" || vendor.Change() != Deleted {
+		t.Errorf("vendor event = %s of %q, want deleted of Prusament", vendor.Change(), vendor.Payload.Name)
+	}
+	if !vendor.Time().IsZero() {
+		t.Errorf("vendor event time = %s, want zero for an invalid date", vendor.Time())
+	}
+}
+
+func TestDecodeEventSkipsUnknownResources(t *testing.T) {
+	event, err := DecodeEvent([]byte(settingsUpdated))
+	if err != nil || event != nil {
+		t.Errorf("settings event = %v, %v, want nil, nil", event, err)
+	}
+}
+
+func TestDecodeEventRejectsBadMessages(t *testing.T) {
+	for _, msg := range []string{
+		`not json`,
+		`{"type": "renamed", "resource": "spool", "payload": {}}`,
+		`{"type": "updated", "resource": "spool", "payload": {"id": "twelve"}}`,
+	} {
+		if _, err := DecodeEvent([]byte(msg)); err == nil {
+			t.Errorf("DecodeEvent(%s) succeeded, want an error", msg)
+		}
+	}
+}
+
+// feedServer serves a change feed that sends one batch of messages per
+// connection. The connection is dropped after a batch unless it is the last.
+func feedServer(t *testing.T, batches ...[]string) *httptest.Server {
+	t.Helper()
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrading: %v", err)
+			return
+		}
+		defer conn.Close()
+		n := int(connections.Add(1)) - 1
+		if n >= len(batches) {
+			n = len(batches) - 1
+		}
+		for _, msg := range batches[n] {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+		if n < len(batches)-1 {
+			return
+		}
+		// Hold the last connection open until the subscriber leaves.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSubscriberReconnects(t *testing.T) {
+	srv := feedServer(t,
+		[]string{settingsUpdated, spoolUpdated},
+		[]string{vendorDeleted},
+	)
+
+	connects := make(chan struct{}, 10)
+	sub := NewSubscriber("ws"+strings.TrimPrefix(srv.URL, "http"), nil, nil, SubscriberOptions{
+		MinReconnect: 10 * time.Millisecond,
+		MaxReconnect: 20 * time.Millisecond,
+		OnConnect:    func() { connects <- struct{}{} },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event, 10)
+	done := make(chan struct{})
+	go func() {
+		sub.Run(ctx, func(e Event) { events <- e })
+		close(done)
+	}()
+
+	next := func() Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return nil
+		}
+	}
+	if e, ok := next().(SpoolEvent); !ok || e.Payload.Id != 12 {
+		t.Fatalf("first event = %#v, want the spool update", e)
+	}
+	// The server dropped the connection, the vendor event arrives on the next
+	if _, ok := next().(VendorEvent); !ok {
+		t.Fatal("second event is not the vendor deletion")
+	}
+
+	for i := range 2 {
+		select {
+		case <-connects:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnConnect called %d times, want 2", i)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %#v", e)
+	case <-connects:
+		t.Error("OnConnect called more than twice")
+	default:
+	}
+}
@@ -0,0 +1,243 @@
+package spoolman
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Longest values Spoolman accepts for the spool text fields.
+const (
+	MaxLocationLength = 64
+	MaxLotNrLength    = 64
+	MaxCommentLength  = 1024
+)
+
+// ErrRejected is returned when Spoolman refuses a spool write it
+// understood, e.g. using more filament than is left.
+var ErrRejected = errors.New("spoolman rejected the update")
+
+// FieldError is a rejected value of a spool write, named by its Spoolman
+// field. Writes return every FieldError joined, see FieldErrors.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// FieldErrors returns the field errors in err, if it is a rejected write.
+func FieldErrors(err error) []*FieldError {
+	var out []*FieldError
+	var walk func(error)
+	walk = func(err error) {
+		if fe, ok := err.(*FieldError); ok {
+			out = append(out, fe)
+			return
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+		} else if e := errors.Unwrap(err); e != nil {
+			walk(e)
+		}
+	}
+	if err != nil {
+		walk(err)
+	}
+	return out
+}
+
+// SpoolUpdate changes fields of a spool. Nil fields are left as they are;
+// an empty string clears a text field and ClearPrice clears the price.
+type SpoolUpdate struct {
+	Location   *string
+	LotNr      *string
+	Comment    *string
+	Price      *float32
+	ClearPrice bool
+	Archived   *bool
+}
+
+// Validate checks the update against Spoolman's limits.
+func (u SpoolUpdate) Validate() error {
+	var errs []error
+	checkLength := func(field string, v *string, limit int) {
+		if v != nil && utf8.RuneCountInString(*v) > limit {
+			errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf("must be at most %d characters", limit)})
+		}
+	}
+	checkLength("location", u.Location, MaxLocationLength)
+	checkLength("lot_nr", u.LotNr, MaxLotNrLength)
+	checkLength("comment", u.Comment, MaxCommentLength)
+	if u.Price != nil {
+		if u.ClearPrice {
+			errs = append(errs, &FieldError{Field: "price", Message: "cannot be set and cleared at once"})
+		} else if *u.Price < 0 || math.IsNaN(float64(*u.Price)) || math.IsInf(float64(*u.Price), 0) {
+			errs = append(errs, &FieldError{Field: "price", Message: "must be a positive number"})
+		}
+	}
+	if u.Location == nil && u.LotNr == nil && u.Comment == nil && u.Price == nil && !u.ClearPrice && u.Archived == nil {
+		errs = append(errs, &FieldError{Field: "update", Message: "must change at least one field"})
+	}
+	return errors.Join(errs...)
+}
+
+// null is the JSON value that clears a field in Spoolman.
+var null = json.RawMessage("null")
+
+// UpdateSpool applies u to a spool and returns the updated spool.
+func (s *Service) UpdateSpool(ctx context.Context, spoolID int, u SpoolUpdate) (*Spool, error) {
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	var params SpoolUpdateParameters
+	params.Archived = u.Archived
+	// Empty text clears the field
+	text := func(v string) json.RawMessage {
+		if v = strings.TrimSpace(v); v == "" {
+			return null
+		}
+		b, _ := json.Marshal(v)
+		return b
+	}
+	if u.Location != nil {
+		params.Location = &SpoolUpdateParameters_Location{union: text(*u.Location)}
+	}
+	if u.LotNr != nil {
+		params.LotNr = &SpoolUpdateParameters_LotNr{union: text(*u.LotNr)}
+	}
+	if u.Comment != nil {
+		params.Comment = &SpoolUpdateParameters_Comment{union: text(*u.Comment)}
+	}
+	if u.Price != nil {
+		var price SpoolUpdateParameters_Price
+		if err := price.FromSpoolUpdateParametersPrice0(*u.Price); err != nil {
+			return nil, err
+		}
+		params.Price = &price
+	} else if u.ClearPrice {
+		params.Price = &SpoolUpdateParameters_Price{union: null}
+	}
+
+	rsp, err := s.client.UpdateSpoolSpoolSpoolIdPatchWithResponse(ctx, spoolID, params)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() == http.StatusOK && rsp.JSON200 != nil {
+		return rsp.JSON200, nil
+	}
+	return nil, writeError(spoolID, rsp.StatusCode(), rsp.JSON400, rsp.JSON422)
+}
+
+// SpoolUse is filament taken from a spool, by length in millimeters or by
+// weight in grams. Exactly one of them is set.
+type SpoolUse struct {
+	Length float32
+	Weight float32
+}
+
+// Validate checks that the use is a single positive amount.
+func (u SpoolUse) Validate() error {
+	positive := func(field string, v float32) error {
+		if v < 0 || math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return &FieldError{Field: field, Message: "must be a positive number"}
+		}
+		return nil
+	}
+	if err := errors.Join(positive("use_length", u.Length), positive("use_weight", u.Weight)); err != nil {
+		return err
+	}
+	switch {
+	case u.Length == 0 && u.Weight == 0:
+		return &FieldError{Field: "use_weight", Message: "or use_length is required"}
+	case u.Length != 0 && u.Weight != 0:
+		return &FieldError{Field: "use_weight", Message: "and use_length cannot both be set"}
+	}
+	return nil
+}
+
+// UseSpool records filament taken from a spool and returns the updated
+// spool.
+func (s *Service) UseSpool(ctx context.Context, spoolID int, u SpoolUse) (*Spool, error) {
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	var params SpoolUseParameters
+	if u.Length > 0 {
+		var length SpoolUseParameters_UseLength
+		if err := length.FromSpoolUseParametersUseLength0(u.Length); err != nil {
+			return nil, err
+		}
+		params.UseLength = &length
+	} else {
+		var weight SpoolUseParameters_UseWeight
+		if err := weight.FromSpoolUseParametersUseWeight0(u.Weight); err != nil {
+			return nil, err
+		}
+		params.UseWeight = &weight
+	}
+
+	rsp, err := s.client.UseSpoolFilamentSpoolSpoolIdUsePutWithResponse(ctx, spoolID, params)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode() == http.StatusOK && rsp.JSON200 != nil {
+		return rsp.JSON200, nil
+	}
+	return nil, writeError(spoolID, rsp.StatusCode(), rsp.JSON400, rsp.JSON422)
+}
+
+// writeError turns an unsuccessful spool write response into an error:
+// ErrNotFound, the field errors Spoolman reported or ErrRejected with its
+// message.
+func writeError(spoolID, status int, rejected *Message, invalid *HTTPValidationError) error {
+	if status == http.StatusNotFound {
+		return fmt.Errorf("spool %d: %w", spoolID, ErrNotFound)
+	}
+	return responseError(status, rejected, invalid)
+}
+
+// responseError turns an unsuccessful Spoolman response into an error: the
+// field errors Spoolman reported, ErrRejected with its message or the
+// unexpected status.
+func responseError(status int, rejected *Message, invalid *HTTPValidationError) error {
+	switch status {
+	case http.StatusBadRequest:
+		if rejected != nil {
+			return fmt.Errorf("%w: %s", ErrRejected, rejected.Message)
+		}
+	case http.StatusUnprocessableEntity:
+		if invalid != nil && invalid.Detail != nil {
+			var errs []error
+			for _, d := range *invalid.Detail {
+				errs = append(errs, &FieldError{Field: validationField(d), Message: d.Msg})
+			}
+			return errors.Join(errs...)
+		}
+	}
+	log.Printf("Expected HTTP 200 but received %d", status)
+	return fmt.Errorf("expected HTTP 200 but received %d", status)
+}
+
+// validationField names the field of a Spoolman validation error, whose
+// location is e.g. ["body", "price"].
+func validationField(e ValidationError) string {
+	for i := len(e.Loc) - 1; i >= 0; i-- {
+		if field, err := e.Loc[i].AsValidationErrorLoc0(); err == nil {
+			return field
+		}
+	}
+	return "body"
+}
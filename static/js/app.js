@@ -288,6 +288,28 @@ document.addEventListener("DOMContentLoaded", function () {
       }
     },
 
+    // writeSpoolLink links a tag to a spool, keeping its OPT record as it
+    // was read (e.g. a vendor tag) instead of rebuilding it.
+    writeSpoolLink: async (spool_id, optPayload) => {
+      try {
+        logToConsoleAndDom("Approach NFC tag to link it to the spool...");
+        const msg = window.fcTagModels.SpoolTag.buildNdefWriteMessage({
+          optPayloadBytes: optPayload.toBytes(),
+          spoolmanJsonBytes: window.fcRecords.encodeSpoolmanLinkPayload(
+            Number(spool_id)
+          ),
+        });
+        logToConsoleAndDom("Writing spool link...");
+        await window.fcWebNfc.writeMessage(msg);
+        logToConsoleAndDom("Wrote spool link OK");
+        return true;
+      } catch (error) {
+        const message = error && error.message ? error.message : String(error);
+        logToConsoleAndDom("Error writing spool link: " + message);
+        return false;
+      }
+    },
+
     writeLocationTagInit: async (location) => {
       try {
         logToConsoleAndDom("Approach NFC tag to write location tag...");
@@ -395,6 +417,438 @@ document.addEventListener("DOMContentLoaded", function () {
       );
     }
 
+    function bindSpoolDetailLocate() {
+      const btn = byId("fc-locate-spool");
+      if (!btn) return;
+
+      const spoolId = btn.getAttribute("data-spool-id");
+      if (!spoolId) return;
+
+      btn.addEventListener("click", async function () {
+        setButtonDisabled(btn, true);
+        try {
+          const rsp = await fetch(
+            "/api/spool/" + encodeURIComponent(spoolId) + "/locate",
+            { method: "POST", headers: { Accept: "application/json" } }
+          );
+          if (!rsp.ok) {
+            const errorText = await rsp.text();
+            throw new Error(errorText.trim() || "HTTP " + rsp.status);
+          }
+          const result = await rsp.json();
+          toast({
+            type: "success",
+            message:
+              "Lighting " +
+              result.location +
+              " for " +
+              Math.round(result.duration_ms / 1000) +
+              "s",
+          });
+        } catch (e) {
+          const msg = e && e.message ? String(e.message) : String(e);
+          toast({ type: "error", message: "Could not locate spool: " + msg });
+        } finally {
+          setButtonDisabled(btn, false);
+        }
+      });
+    }
+
+    // bindSpoolDetailEdit wires the edit form, the archive button and the
+    // usage form of the spool detail page. Every write reloads the page so
+    // it shows the spool as Spoolman has it.
+    function bindSpoolDetailEdit() {
+      async function writeSpool(spoolId, method, path, body) {
+        const rsp = await fetch("/api/spool/" + encodeURIComponent(spoolId) + path, {
+          method: method,
+          headers: { "Content-Type": "application/json", Accept: "application/json" },
+          body: JSON.stringify(body),
+        });
+        if (!rsp.ok) {
+          throw new Error(apiErrorMessage(await rsp.text()) || "HTTP " + rsp.status);
+        }
+        return rsp.json();
+      }
+
+      function bindForm(form, failure, buildBody) {
+        if (!form) return;
+        const spoolId = form.getAttribute("data-spool-id");
+        if (!spoolId) return;
+        const btn = form.querySelector("button[type=submit]");
+
+        form.addEventListener("submit", async function (ev) {
+          ev.preventDefault();
+          const req = buildBody(form);
+          if (!req) return;
+          setButtonDisabled(btn, true);
+          try {
+            await writeSpool(spoolId, req.method, req.path, req.body);
+            toast({ type: "success", message: req.success });
+            window.location.reload();
+          } catch (e) {
+            const msg = e && e.message ? String(e.message) : String(e);
+            toast({ type: "error", message: failure + ": " + msg });
+            setButtonDisabled(btn, false);
+          }
+        });
+      }
+
+      // Only changed fields are sent; empty fields clear the value
+      bindForm(byId("fc-edit-spool"), "Could not save spool", function (form) {
+        const body = {};
+        ["location", "lot_nr", "comment"].forEach(function (name) {
+          const el = form.elements[name];
+          if (el.value !== el.defaultValue) body[name] = el.value;
+        });
+        const price = form.elements.price;
+        if (price.value !== price.defaultValue) {
+          body.price = price.value === "" ? null : Number(price.value);
+        }
+        if (Object.keys(body).length === 0) {
+          toast({ type: "info", message: "Nothing to save" });
+          return null;
+        }
+        return { method: "PATCH", path: "", body: body, success: "Spool saved" };
+      });
+
+      bindForm(byId("fc-use-spool"), "Could not record usage", function (form) {
+        const amount = Number(form.elements.amount.value);
+        if (!(amount > 0)) {
+          toast({ type: "error", message: "Enter the amount of filament used" });
+          return null;
+        }
+        // The API takes lengths in millimeters
+        const body =
+          form.elements.unit.value === "m"
+            ? { length: amount * 1000 }
+            : { weight: amount };
+        return { method: "POST", path: "/use", body: body, success: "Usage recorded" };
+      });
+
+      const archive = byId("fc-archive-spool");
+      if (archive) {
+        const spoolId = archive.getAttribute("data-spool-id");
+        const archived = archive.getAttribute("data-archived") === "true";
+        archive.addEventListener("click", async function () {
+          setButtonDisabled(archive, true);
+          try {
+            await writeSpool(spoolId, "PATCH", "", { archived: !archived });
+            toast({ type: "success", message: archived ? "Spool unarchived" : "Spool archived" });
+            window.location.reload();
+          } catch (e) {
+            const msg = e && e.message ? String(e.message) : String(e);
+            toast({ type: "error", message: "Could not update spool: " + msg });
+            setButtonDisabled(archive, false);
+          }
+        });
+      }
+    }
+
+    // bindSpoolWeigh wires the weigh-in page: typing a weight previews the
+    // remaining filament and any warnings, submitting records it. A weight
+    // with warnings is only confirmed once its warnings were shown.
+    function bindSpoolWeigh() {
+      const form = byId("fc-weigh-spool");
+      if (!form) return;
+      const spoolId = form.getAttribute("data-spool-id");
+      if (!spoolId) return;
+
+      const input = form.elements.weight;
+      const btn = form.querySelector("button[type=submit]");
+      const before = byId("fc-weigh-before");
+      const after = byId("fc-weigh-after");
+      const warnings = byId("fc-weigh-warnings");
+
+      // warned is the weight whose warnings are on screen
+      let warned = null;
+
+      async function weigh(dryRun, confirm) {
+        const rsp = await fetch("/api/spool/" + encodeURIComponent(spoolId) + "/weigh", {
+          method: "POST",
+          headers: { "Content-Type": "application/json", Accept: "application/json" },
+          body: JSON.stringify({
+            weight: Number(input.value),
+            dry_run: dryRun,
+            confirm: confirm,
+          }),
+        });
+        if (!rsp.ok) {
+          const text = await rsp.text();
+          const err = new Error(apiErrorMessage(text) || "HTTP " + rsp.status);
+          try {
+            err.warnings = JSON.parse(text).warnings;
+          } catch (e) {
+            // Not JSON
+          }
+          throw err;
+        }
+        return rsp.json();
+      }
+
+      // Notices are listed with the warnings but need no confirmation
+      function showWarnings(list, gross, notices) {
+        warnings.replaceChildren();
+        (list || []).concat(notices || []).forEach(function (text) {
+          const li = document.createElement("li");
+          li.textContent = text;
+          warnings.appendChild(li);
+        });
+        warned = list && list.length ? gross : null;
+        btn.textContent = warned === null ? "Record" : "Record anyway";
+      }
+
+      function show(result) {
+        const w = result.weighing;
+        before.textContent = Math.round(w.before) + "g";
+        after.textContent = Math.round(w.after) + "g";
+        showWarnings(w.warnings, w.gross, w.notices);
+      }
+
+      function reset() {
+        after.textContent = "—";
+        showWarnings([], 0);
+      }
+
+      let previewTimer = null;
+      input.addEventListener("input", function () {
+        window.clearTimeout(previewTimer);
+        if (!(Number(input.value) > 0)) {
+          reset();
+          return;
+        }
+        previewTimer = window.setTimeout(async function () {
+          try {
+            show(await weigh(true, false));
+          } catch (e) {
+            // The preview is best effort; recording reports errors
+          }
+        }, 300);
+      });
+
+      // Stable weights from a networked scale fill in the form, and weighings
+      // recorded for this spool after a tag scan are shown
+      if (window.EventSource) {
+        const live = new EventSource("/api/events");
+        live.addEventListener("scale", function (ev) {
+          const state = JSON.parse(ev.data);
+          if (!state.stable || state.grams <= 0 || document.activeElement === input) return;
+          input.value = String(Math.round(state.grams));
+          input.dispatchEvent(new Event("input"));
+        });
+        live.addEventListener("weighing", function (ev) {
+          const weighing = JSON.parse(ev.data);
+          if (String(weighing.spool_id) !== spoolId) return;
+          if (weighing.weighing.gross) show({ weighing: weighing.weighing });
+          if (weighing.recorded) {
+            toast({
+              type: "success",
+              message:
+                "Weighed on " + weighing.scale + ": " +
+                Math.round(weighing.weighing.before) + "g → " +
+                Math.round(weighing.weighing.after) + "g",
+            });
+          } else {
+            toast({ type: "error", message: weighing.error || "Weighing was not recorded" });
+          }
+        });
+      }
+
+      form.addEventListener("submit", async function (ev) {
+        ev.preventDefault();
+        window.clearTimeout(previewTimer);
+        if (!(Number(input.value) > 0)) {
+          toast({ type: "error", message: "Enter the weight shown by the scale" });
+          return;
+        }
+        setButtonDisabled(btn, true);
+        try {
+          const weight = Number(input.value);
+          const result = await weigh(false, warned === weight);
+          show(result);
+          showWarnings([], 0);
+          toast({
+            type: "success",
+            message:
+              "Remaining " +
+              Math.round(result.weighing.before) +
+              "g → " +
+              Math.round(result.weighing.after) +
+              "g",
+          });
+          input.value = "";
+        } catch (e) {
+          if (e && e.warnings && e.warnings.length) {
+            // Not recorded yet: show why, submitting again confirms
+            showWarnings(e.warnings, Number(input.value));
+            toast({
+              type: "error",
+              message:
+                "Check the warnings, then record anyway if the weight is right",
+            });
+            return;
+          }
+          const msg = e && e.message ? String(e.message) : String(e);
+          toast({ type: "error", message: "Could not record weight: " + msg });
+        } finally {
+          setButtonDisabled(btn, false);
+        }
+      });
+    }
+
+    // notifyScaleScan tells the server which spool was scanned, so the
+    // next stable weight on a scale is recorded for it. Best effort: without
+    // a scale nothing happens.
+    function notifyScaleScan(spoolId) {
+      fetch("/api/scale/scan", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ spool_id: spoolId }),
+      }).catch(function (e) {
+        console.warn("Could not notify the scale:", e);
+      });
+    }
+
+    // apiErrorMessage turns a structured API error body ({error, message,
+    // fields}) into a readable message. Plain text bodies are returned as is.
+    function apiErrorMessage(text) {
+      try {
+        const err = JSON.parse(text);
+        if (err && err.message) {
+          const details = (err.fields || []).map(function (f) {
+            return f.field.replace(/^records\[0\]\.value\./, "") + " " + f.message;
+          });
+          return details.length
+            ? err.message + ": " + details.join("; ")
+            : err.message;
+        }
+      } catch (e) {
+        // Not JSON
+      }
+      return text.trim();
+    }
+
+    // newEventId returns an idempotency key for a transfer.
+    // crypto.randomUUID is only available in secure contexts.
+    function newEventId() {
+      if (window.crypto && window.crypto.randomUUID) {
+        return window.crypto.randomUUID();
+      }
+      const bytes = new Uint8Array(16);
+      window.crypto.getRandomValues(bytes);
+      return Array.from(bytes, function (b) {
+        return b.toString(16).padStart(2, "0");
+      }).join("");
+    }
+
+    // waitForTransfer long-polls the server until the outcome of a transfer
+    // is known and reports it as a toast.
+    async function waitForTransfer(eventId) {
+      for (;;) {
+        const response = await fetch(
+          "/api/transfers/" + encodeURIComponent(eventId) + "?wait=30s"
+        );
+        if (!response.ok) {
+          throw new Error(apiErrorMessage(await response.text()) || response.status);
+        }
+        const transfer = await response.json();
+        const spool = "Spool #" + transfer.spool_id;
+        switch (transfer.status) {
+          case "completed":
+            toast({
+              type: "success",
+              message: spool + " moved to " + transfer.location,
+            });
+            return transfer;
+          case "failed":
+            toast({
+              type: "error",
+              message:
+                spool + " was not moved to " + transfer.location + ": " +
+                (transfer.error || "unknown error"),
+            });
+            return transfer;
+          case "unconfirmed":
+            toast({
+              type: "info",
+              message:
+                "No confirmation yet that " + spool.toLowerCase() +
+                " moved to " + transfer.location + ".",
+            });
+            return transfer;
+        }
+        // Still initiated, keep waiting
+      }
+    }
+
+    function bindVirtualChamber() {
+      const root = byId("fc-sim");
+      if (!root) return;
+
+      const updated = byId("fc-sim-updated");
+      const strips = byId("fc-sim-strips");
+      const cells = root.querySelectorAll("[data-sim-led]");
+
+      function rgb(color) {
+        if (!color || color.length < 3) return "rgb(0, 0, 0)";
+        return "rgb(" + color[0] + ", " + color[1] + ", " + color[2] + ")";
+      }
+
+      function isLit(color) {
+        return color && (color[0] > 0 || color[1] > 0 || color[2] > 0);
+      }
+
+      function render(state) {
+        const leds = state.leds || {};
+        cells.forEach(function (cell) {
+          const color = leds[cell.getAttribute("data-sim-led")];
+          cell.style.backgroundColor = rgb(color);
+          cell.style.boxShadow = isLit(color) ? "0 0 12px 2px " + rgb(color) : "";
+        });
+
+        const frames = state.frames || [];
+        strips.replaceChildren();
+        frames.forEach(function (frame) {
+          const row = document.createElement("div");
+          row.className = "flex items-center gap-2";
+          const label = document.createElement("span");
+          label.className = "w-40 truncate";
+          label.textContent = frame.controller + " pin " + frame.pin;
+          row.appendChild(label);
+          (frame.colors || []).forEach(function (color) {
+            const dot = document.createElement("span");
+            dot.className = "inline-block w-3 h-3 rounded-full border border-gray-600";
+            dot.style.backgroundColor = rgb(color);
+            row.appendChild(dot);
+          });
+          strips.appendChild(row);
+        });
+
+        let last = null;
+        frames.forEach(function (frame) {
+          const t = new Date(frame.updated);
+          if (!last || t > last) last = t;
+        });
+        if (updated) {
+          updated.textContent = last
+            ? "Last frame " + last.toLocaleTimeString()
+            : "Waiting for frames...";
+        }
+      }
+
+      async function poll() {
+        try {
+          const rsp = await fetch("/api/simulator/leds", {
+            headers: { Accept: "application/json" },
+          });
+          if (rsp.ok) render(await rsp.json());
+        } catch (e) {
+          console.warn("Simulator poll failed", e);
+        }
+        setTimeout(poll, 200);
+      }
+      poll();
+    }
+
     function bindSpoolDetailWrite() {
       const btn = byId("fc-write-spool-tag");
       if (!btn) return;
@@ -884,6 +1338,58 @@ document.addEventListener("DOMContentLoaded", function () {
         if (colorEl) colorEl.style.backgroundColor = spoolColor;
       }
 
+      // The OPT payload and its main section of a tag waiting to be
+      // registered as a new spool
+      let registerTag = null;
+
+      function showRegisterModal(optPayload) {
+        const translated = optPayload
+          ? window.fcOptTranslator.translateOptPayload(optPayload)
+          : null;
+        const main =
+          translated && translated.readable && translated.readable.main;
+        if (!main) return false;
+        registerTag = { optPayload: optPayload, main: main };
+
+        const name =
+          [main.brand_name, main.material_name].filter(Boolean).join(" ") ||
+          "Unknown Spool";
+        const details = [];
+        if (main.nominal_netto_full_weight) {
+          details.push(main.nominal_netto_full_weight + " g");
+        }
+        if (main.filament_diameter) {
+          details.push(main.filament_diameter + " mm");
+        }
+
+        const nameEl = byId("fc-register-name");
+        const materialEl = byId("fc-register-material");
+        const detailsEl = byId("fc-register-details");
+        const colorEl = byId("fc-register-color");
+        if (nameEl) nameEl.textContent = name;
+        if (materialEl) materialEl.textContent = main.material_type || "-";
+        if (detailsEl) detailsEl.textContent = details.join(" · ") || "-";
+        if (colorEl) {
+          colorEl.style.backgroundColor = main.primary_color
+            ? String(main.primary_color).slice(0, 7)
+            : "#cccccc";
+        }
+
+        const modal = byId("fc-register-modal");
+        if (!modal) return false;
+        modal.classList.remove("hidden");
+        modal.setAttribute("aria-hidden", "false");
+        return true;
+      }
+
+      function hideRegisterModal() {
+        const modal = byId("fc-register-modal");
+        if (modal) {
+          modal.classList.add("hidden");
+          modal.setAttribute("aria-hidden", "true");
+        }
+      }
+
       function populateLocationModal(spoolData) {
         const spoolName = spoolData.name || "Unknown Spool";
         const spoolNameEl = byId("fc-location-spool-name");
@@ -912,6 +1418,10 @@ document.addEventListener("DOMContentLoaded", function () {
             out && out.spool ? Number(out.spool.spool_id) : Number.NaN;
 
           if (!Number.isFinite(spoolId) || spoolId <= 0) {
+            // A vendor OpenPrintTag is not linked to a spool yet
+            if (out && out.spool && showRegisterModal(out.spool.optPayload)) {
+              return;
+            }
             toast({
               type: "error",
               message: "This tag is not a spool tag (no spool_id found).",
@@ -919,6 +1429,9 @@ document.addEventListener("DOMContentLoaded", function () {
             return;
           }
 
+          // A spool put on a scale now is weighed in for this spool
+          notifyScaleScan(spoolId);
+
           // Store the scanned data for later use
           scannedSpoolData = {
             spool_id: spoolId,
@@ -966,6 +1479,88 @@ document.addEventListener("DOMContentLoaded", function () {
         }
       });
 
+      // Register modal: add the spool to Spoolman, then link the tag to it
+      const registerConfirmBtn = byId("fc-register-confirm");
+      if (registerConfirmBtn) {
+        registerConfirmBtn.addEventListener("click", async function () {
+          if (!registerTag) return;
+          const tag = registerTag;
+          registerTag = null;
+          hideRegisterModal();
+          setButtonDisabled(registerConfirmBtn, true);
+          showModal("Registering spool…");
+
+          try {
+            const rsp = await fetch("/api/spools/register", {
+              method: "POST",
+              headers: { "Content-Type": "application/json" },
+              // Binary fields (UUIDs) are not needed and 64-bit integers
+              // cannot be sent as JSON numbers
+              body: JSON.stringify({ main: tag.main }, function (key, value) {
+                if (value instanceof Uint8Array) return undefined;
+                if (typeof value === "bigint") return String(value);
+                return value;
+              }),
+            });
+            const text = await rsp.text();
+            if (!rsp.ok) {
+              hideModal();
+              toast({
+                type: "error",
+                message:
+                  "Could not register the spool: " + apiErrorMessage(text),
+                timeoutMs: 8000,
+              });
+              return;
+            }
+            const spoolId = JSON.parse(text).spool.id;
+
+            showModal(
+              "Spool #" +
+                spoolId +
+                " registered. Approach the tag again to link it…"
+            );
+            const ok = await window.fcNfc.writeSpoolLink(
+              spoolId,
+              tag.optPayload
+            );
+            hideModal();
+            if (!ok) {
+              toast({
+                type: "error",
+                message:
+                  "Spool #" +
+                  spoolId +
+                  " was registered, but the tag could not be linked." +
+                  " Write it from the spool page.",
+                timeoutMs: 8000,
+              });
+              return;
+            }
+            window.location.href = "/spool/" + String(spoolId);
+          } catch (e) {
+            hideModal();
+            const msg = e && e.message ? String(e.message) : String(e);
+            toast({
+              type: "error",
+              message: "Error registering spool: " + msg,
+            });
+          } finally {
+            setButtonDisabled(registerConfirmBtn, false);
+          }
+        });
+      }
+
+      const registerCancelBtn = byId("fc-register-cancel");
+      const registerBackdrop = byId("fc-register-modal-backdrop");
+      [registerCancelBtn, registerBackdrop].forEach(function (el) {
+        if (!el) return;
+        el.addEventListener("click", function () {
+          hideRegisterModal();
+          registerTag = null;
+        });
+      });
+
       // Action modal: View Details button
       const viewDetailsBtn = byId("fc-action-view-details");
       if (viewDetailsBtn) {
@@ -1092,6 +1687,8 @@ document.addEventListener("DOMContentLoaded", function () {
                     spoolId: String(scannedSpoolData.spool_id),
                     locationId: locationId,
                     timestamp: timestamp,
+                    // Idempotency key: resending this event is harmless
+                    eventId: newEventId(),
                     tagData: {},
                   },
                 },
@@ -1114,7 +1711,7 @@ document.addEventListener("DOMContentLoaded", function () {
               }
             }
 
-            // Send to backend API endpoint (proxies to Kafka)
+            // Send to backend API endpoint (Kafka or Spoolman, per transfer mode)
             const response = await fetch("/api/transfer-location", {
               method: "POST",
               headers: {
@@ -1126,16 +1723,41 @@ document.addEventListener("DOMContentLoaded", function () {
             if (!response.ok) {
               const errorText = await response.text();
               throw new Error(
-                "Transfer request failed: " + (errorText || response.status)
+                "Transfer request failed: " +
+                  (apiErrorMessage(errorText) || response.status)
               );
             }
 
-            // Success!
-            hideLocationModal();
-            toast({
-              type: "success",
-              message: "Location transfer initiated successfully!",
+            // Accepted. In spoolman transfer mode the response is the
+            // updated spool and the transfer is done; otherwise wait for the
+            // outcome in the background.
+            const result = await response.json().catch(function () {
+              return null;
             });
+            const eventId = payload.records[0].value.eventId;
+            hideLocationModal();
+            if (result && result.id && result.location !== undefined) {
+              toast({
+                type: "success",
+                message:
+                  "Spool #" + result.id + " moved to " + (result.location || "-"),
+              });
+            } else {
+              toast({
+                type: "info",
+                message:
+                  response.status === 202
+                    ? "Transfer saved, it will be delivered when the server is reachable again."
+                    : "Location transfer initiated, waiting for confirmation...",
+              });
+              waitForTransfer(eventId).catch(function (e) {
+                const msg = e && e.message ? String(e.message) : String(e);
+                toast({
+                  type: "error",
+                  message: "Lost track of the transfer: " + msg,
+                });
+              });
+            }
 
             // Reset state
             scannedSpoolData = null;
@@ -1183,8 +1805,59 @@ document.addEventListener("DOMContentLoaded", function () {
       }
     }
 
+    // Changing a filter starts the list over at the first page. This runs
+    // in the capture phase, before htmx sends the form.
+    function bindFilterPaging() {
+      const form = byId("filter-form");
+      if (!form) return;
+      function resetPage(e) {
+        if (e.target && e.target.name === "page") return;
+        const page = byId("filter-page");
+        if (page) page.value = "1";
+      }
+      form.addEventListener("input", resetPage, true);
+      form.addEventListener("change", resetPage, true);
+    }
+
+    // The color picker fills in the hex color filter, and the tolerance
+    // slider shows its value.
+    function bindColorFilter() {
+      const form = byId("filter-form");
+      const picker = byId("filter-color-picker");
+      const hex = byId("filter-color");
+      if (!form || !picker || !hex) return;
+
+      picker.addEventListener("change", function () {
+        hex.value = picker.value.replace("#", "").toUpperCase();
+        if (window.htmx) window.htmx.trigger(form, "submit");
+      });
+      hex.addEventListener("input", function () {
+        const v = hex.value.trim().replace(/^#/, "");
+        if (/^[0-9a-fA-F]{6}$/.test(v)) picker.value = "#" + v.toLowerCase();
+      });
+
+      const tolerance = byId("filter-tolerance");
+      const toleranceValue = byId("filter-tolerance-value");
+      if (tolerance && toleranceValue) {
+        tolerance.addEventListener("input", function () {
+          toleranceValue.textContent = tolerance.value;
+        });
+        form.addEventListener("reset", function () {
+          setTimeout(function () {
+            toleranceValue.textContent = tolerance.value;
+          });
+        });
+      }
+    }
+
+    bindFilterPaging();
+    bindColorFilter();
     bindSpoolDetailWrite();
+    bindSpoolDetailLocate();
     bindAdminNfcTools();
+    bindVirtualChamber();
     bindSpoolsScanNavigate();
+    bindSpoolDetailEdit();
+    bindSpoolWeigh();
   });
 })();
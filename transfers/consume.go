@@ -0,0 +1,117 @@
+package transfers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tryy3/filament-chamber/backoff"
+	"github.com/tryy3/filament-chamber/events"
+	"github.com/tryy3/filament-chamber/spoolman"
+)
+
+// Outcome is a message on the transfer result topic, published by whoever
+// applies the initiated transfers.
+type Outcome struct {
+	// EventID is the eventId of the initiated transfer. Outcomes without it
+	// are matched by spool and location.
+	EventID    string           `json:"eventId"`
+	SpoolID    spoolman.SpoolID `json:"spoolId"`
+	LocationID string           `json:"locationId"`
+	// Status is "completed" or "failed".
+	Status Status `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Apply resolves the transfer the outcome refers to. It returns the event ID
+// of the resolved transfer and false if no tracked transfer was waiting for
+// the outcome.
+func (t *Tracker) Apply(o Outcome) (string, bool, error) {
+	var cause error
+	switch o.Status {
+	case StatusCompleted:
+	case StatusFailed:
+		cause = errors.New(o.Error)
+		if o.Error == "" {
+			cause = errors.New("the transfer failed")
+		}
+	default:
+		return "", false, fmt.Errorf("unknown transfer status %q", o.Status)
+	}
+	if o.EventID != "" {
+		return o.EventID, t.Resolve(o.EventID, cause), nil
+	}
+	eventID, ok := t.ResolveMatch(int(o.SpoolID), o.LocationID, cause)
+	return eventID, ok, nil
+}
+
+// Backoff bounds between consumer reconnects.
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+)
+
+// pollTimeout is how long the proxy holds a poll open waiting for records.
+const pollTimeout = 5 * time.Second
+
+// Consume reads outcomes from topic as a member of group and resolves the
+// matching transfers, until ctx is cancelled. The consumer is recreated
+// with backoff when the proxy fails or drops it.
+func (t *Tracker) Consume(ctx context.Context, client *events.Client, group, topic string) {
+	b := backoff.Backoff{Min: minReconnect, Max: maxReconnect}
+	b.Reconnect(ctx, func(connected func()) error {
+		return t.consume(ctx, client, group, topic, connected)
+	}, func(err error, delay time.Duration) {
+		slog.Warn("transfer result consumer stopped, reconnecting", "topic", topic, "in", delay, "error", err)
+	})
+}
+
+// consume runs one consumer instance until an error. connected is called
+// once the subscription is in place.
+func (t *Tracker) consume(ctx context.Context, client *events.Client, group, topic string, connected func()) error {
+	consumer, err := client.NewConsumer(ctx, group, events.ConsumerOptions{
+		OffsetReset: "latest",
+		AutoCommit:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("creating consumer: %w", err)
+	}
+	defer func() {
+		// Leave the group even when ctx is already cancelled.
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := consumer.Close(closeCtx); err != nil {
+			slog.Debug("closing transfer result consumer", "error", err)
+		}
+	}()
+	if err := consumer.Subscribe(ctx, topic); err != nil {
+		return fmt.Errorf("subscribing to %s: %w", topic, err)
+	}
+	slog.Info("consuming transfer results", "topic", topic, "group", group, "consumer", consumer.ID())
+	connected()
+
+	for {
+		messages, err := consumer.Poll(ctx, pollTimeout)
+		if err != nil {
+			return fmt.Errorf("polling %s: %w", topic, err)
+		}
+		for _, m := range messages {
+			var o Outcome
+			if err := json.Unmarshal(m.Value, &o); err != nil {
+				slog.Warn("skipping unreadable transfer result", "partition", m.Partition, "offset", m.Offset, "error", err)
+				continue
+			}
+			eventID, ok, err := t.Apply(o)
+			if err != nil {
+				slog.Warn("skipping transfer result", "partition", m.Partition, "offset", m.Offset, "error", err)
+				continue
+			}
+			if ok {
+				slog.Info("transfer resolved", "event_id", eventID, "spool_id", int(o.SpoolID), "status", o.Status)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package transfers tracks location transfers from the moment they are
+// initiated until their outcome is known, so the browser that started a
+// transfer can be told whether the spool actually moved.
+//
+// Outcomes come from a Kafka topic the transfer consumer reports to (see
+// Consume), from watching the spool location in Spoolman (see Watch), or
+// directly from whoever applied the transfer (see Resolve).
+package transfers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state of a tracked transfer.
+type Status string
+
+const (
+	// StatusInitiated transfers wait to be sent or for their outcome.
+	StatusInitiated Status = "initiated"
+	// StatusCompleted transfers moved the spool.
+	StatusCompleted Status = "completed"
+	// StatusFailed transfers were rejected, see Transfer.Error.
+	StatusFailed Status = "failed"
+	// StatusUnconfirmed transfers got no outcome within the timeout after
+	// they were sent.
+	StatusUnconfirmed Status = "unconfirmed"
+)
+
+// Without other settings a transfer is unconfirmed two minutes after it was
+// sent, and its outcome can be looked up for ten minutes more.
+const (
+	DefaultTimeout   = 2 * time.Minute
+	DefaultRetention = 10 * time.Minute
+)
+
+// ErrNotFound is returned for transfers that are not tracked.
+var ErrNotFound = errors.New("transfer not found")
+
+// Transfer is the tracked state of one location transfer.
+type Transfer struct {
+	EventID  string    `json:"event_id"`
+	SpoolID  int       `json:"spool_id"`
+	Location string    `json:"location"`
+	Status   Status    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Created  time.Time `json:"created"`
+	// Sent is when the transfer left the outbox. Its timeout starts then.
+	Sent     time.Time `json:"sent,omitzero"`
+	Resolved time.Time `json:"resolved,omitzero"`
+}
+
+// Done reports whether the transfer has its final status.
+func (t Transfer) Done() bool {
+	return t.Status != StatusInitiated
+}
+
+// Options set how long a Tracker waits for outcomes and keeps them, and who
+// hears about them. Durations that are not set use DefaultTimeout and
+// DefaultRetention.
+type Options struct {
+	// Timeout marks transfers unconfirmed when no outcome arrives this long
+	// after they were sent. Transfers still waiting in the outbox never
+	// time out.
+	Timeout time.Duration
+	// Retention is how long finished transfers can still be looked up.
+	Retention time.Duration
+	// OnChange is called when a transfer is tracked, sent or finished, in the
+	// order the changes happen. Calls are made while the change is applied,
+	// so publishing the transfer is fine but calling back into the Tracker
+	// deadlocks.
+	OnChange func(Transfer)
+}
+
+type tracked struct {
+	Transfer
+	done chan struct{}
+}
+
+// Tracker holds the transfers of the last few minutes in memory.
+type Tracker struct {
+	opts Options
+
+	mu        sync.Mutex
+	transfers map[string]*tracked
+}
+
+func New(opts Options) *Tracker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	return &Tracker{
+		opts:      opts,
+		transfers: map[string]*tracked{},
+	}
+}
+
+// Track starts tracking a transfer. Tracking an event ID again is a no-op,
+// so resent events keep their state.
+func (t *Tracker) Track(eventID string, spoolID int, location string) Transfer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr, ok := t.transfers[eventID]; ok {
+		return tr.Transfer
+	}
+	tr := &tracked{
+		Transfer: Transfer{
+			EventID:  eventID,
+			SpoolID:  spoolID,
+			Location: location,
+			Status:   StatusInitiated,
+			Created:  time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	t.transfers[eventID] = tr
+	t.changed(tr)
+	return tr.Transfer
+}
+
+// Resolve records the outcome of a transfer. A non-nil cause marks it
+// failed. It reports false if the transfer is unknown or already finished.
+func (t *Tracker) Resolve(eventID string, cause error) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.transfers[eventID]
+	if !ok {
+		return false
+	}
+	return t.finish(tr, cause)
+}
+
+// Sent records that a transfer was delivered to its receiver, which starts
+// its timeout. Later deliveries of the same transfer do not restart it.
+func (t *Tracker) Sent(eventID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.transfers[eventID]
+	if !ok || tr.Done() || !tr.Sent.IsZero() {
+		return
+	}
+	tr.Sent = time.Now()
+	t.changed(tr)
+}
+
+// ResolveMatch resolves the oldest sent, unfinished transfer of spoolID to
+// location, for outcomes that do not carry the event ID. It returns the
+// event ID of the resolved transfer, if any.
+func (t *Tracker) ResolveMatch(spoolID int, location string, cause error) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var match *tracked
+	for _, tr := range t.transfers {
+		if tr.Done() || tr.Sent.IsZero() || tr.SpoolID != spoolID || !strings.EqualFold(tr.Location, location) {
+			continue
+		}
+		if match == nil || tr.Created.Before(match.Created) {
+			match = tr
+		}
+	}
+	if match == nil {
+		return "", false
+	}
+	return match.EventID, t.finish(match, cause)
+}
+
+// finish sets the final status of tr. t.mu must be held.
+func (t *Tracker) finish(tr *tracked, cause error) bool {
+	if tr.Done() {
+		return false
+	}
+	tr.Status = StatusCompleted
+	if cause != nil {
+		tr.Status = StatusFailed
+		tr.Error = cause.Error()
+	}
+	tr.Resolved = time.Now()
+	close(tr.done)
+	t.changed(tr)
+	return true
+}
+
+// changed reports a state change of tr. t.mu must be held.
+func (t *Tracker) changed(tr *tracked) {
+	if t.opts.OnChange != nil {
+		t.opts.OnChange(tr.Transfer)
+	}
+}
+
+// Get returns the current state of a transfer.
+func (t *Tracker) Get(eventID string) (Transfer, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.transfers[eventID]
+	if !ok {
+		return Transfer{}, ErrNotFound
+	}
+	return tr.Transfer, nil
+}
+
+// Wait blocks until the transfer is finished or ctx is done, and returns its
+// state at that point. A transfer that is still initiated is not an error.
+func (t *Tracker) Wait(ctx context.Context, eventID string) (Transfer, error) {
+	t.mu.Lock()
+	tr, ok := t.transfers[eventID]
+	t.mu.Unlock()
+	if !ok {
+		return Transfer{}, ErrNotFound
+	}
+	select {
+	case <-tr.done:
+	case <-ctx.Done():
+	}
+	return t.Get(eventID)
+}
+
+// Pending returns the unfinished transfers, oldest first.
+func (t *Tracker) Pending() []Transfer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var pending []Transfer
+	for _, tr := range t.transfers {
+		if !tr.Done() {
+			pending = append(pending, tr.Transfer)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Created.Before(pending[j].Created)
+	})
+	return pending
+}
+
+// Run times out transfers without an outcome and forgets finished ones after
+// the retention period, until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.expire(now)
+		}
+	}
+}
+
+func (t *Tracker) expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, tr := range t.transfers {
+		switch {
+		case !tr.Done() && !tr.Sent.IsZero() && now.Sub(tr.Sent) > t.opts.Timeout:
+			tr.Status = StatusUnconfirmed
+			tr.Resolved = now
+			close(tr.done)
+			t.changed(tr)
+		case tr.Done() && now.Sub(tr.Resolved) > t.opts.Retention:
+			delete(t.transfers, id)
+		}
+	}
+}
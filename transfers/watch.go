@@ -0,0 +1,47 @@
+package transfers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch looks up pending transfers.
+const DefaultWatchInterval = 3 * time.Second
+
+// LocationFunc returns the current location of a spool.
+type LocationFunc func(ctx context.Context, spoolID int) (string, error)
+
+// Watch completes sent transfers once the spool shows up at the target
+// location, checking every interval until ctx is cancelled. It is the
+// fallback when nothing reports transfer outcomes. Transfers still in the
+// outbox are skipped: the spool being there already says nothing about
+// them.
+func (t *Tracker) Watch(ctx context.Context, interval time.Duration, location LocationFunc) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, tr := range t.Pending() {
+			if tr.Sent.IsZero() {
+				continue
+			}
+			current, err := location(ctx, tr.SpoolID)
+			if err != nil {
+				slog.Debug("looking up spool location", "spool_id", tr.SpoolID, "error", err)
+				continue
+			}
+			if strings.EqualFold(strings.TrimSpace(current), tr.Location) && t.Resolve(tr.EventID, nil) {
+				slog.Info("transfer resolved", "event_id", tr.EventID, "spool_id", tr.SpoolID, "status", StatusCompleted)
+			}
+		}
+	}
+}